@@ -19,6 +19,52 @@ type FS interface {
 	MkdirAll(path string, perm fs.FileMode) error
 }
 
+// Renamer is an optional extension of FS for filesystems that are able to
+// rename files.
+type Renamer interface {
+	Rename(oldpath, newpath string) error
+}
+
+// Rename renames (moves) oldpath to newpath using the FS when it implements
+// Renamer, otherwise the rename is made directly via the os package.
+func Rename(f FS, oldpath, newpath string) error {
+	if r, ok := f.(Renamer); ok {
+		return r.Rename(oldpath, newpath)
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+// Lstater is an optional extension of FS for filesystems that are able to
+// describe a symbolic link itself rather than the file it refers to.
+type Lstater interface {
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// Lstat returns a FileInfo describing the named file without following a final
+// symbolic link. An error wrapping errors.ErrUnsupported is returned when the
+// FS does not implement Lstater.
+func Lstat(f FS, name string) (fs.FileInfo, error) {
+	if l, ok := f.(Lstater); ok {
+		return l.Lstat(name)
+	}
+	return nil, &fs.PathError{Op: "lstat", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Symlinker is an optional extension of FS for filesystems that are able to
+// create symbolic links.
+type Symlinker interface {
+	Symlink(oldname, newname string) error
+}
+
+// Symlink creates newname as a symbolic link to oldname. An error wrapping
+// errors.ErrUnsupported is returned when the FS does not implement Symlinker.
+func Symlink(f FS, oldname, newname string) error {
+	if s, ok := f.(Symlinker); ok {
+		return s.Symlink(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
 // ReadFile opens a file with the RDONLY flag and returns all bytes from it.
 func ReadFile(f fs.FS, name string) ([]byte, error) {
 	var i fs.File
@@ -93,3 +139,15 @@ func (o *osPT) Remove(name string) error {
 func (o *osPT) MkdirAll(path string, perm fs.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
+
+func (o *osPT) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (o *osPT) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (o *osPT) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/warpstreamlabs/bento/internal/component/testutil"
+	_ "github.com/warpstreamlabs/bento/internal/impl/pure"
 	"github.com/warpstreamlabs/bento/internal/manager/mock"
 	"github.com/warpstreamlabs/bento/internal/message"
 )
@@ -104,6 +106,45 @@ file:
 	}
 }
 
+func TestFileGlobScannerPerFileSource(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "a.csv"), []byte("id,name\n1,foo\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "b.csv"), []byte("code,price,qty\nx,2.5,3\n"), 0o644))
+
+	conf, err := testutil.InputFromYAML(fmt.Sprintf(`
+file:
+  paths: [ "%v/*.csv" ]
+  scanner:
+    csv: {}
+`, tmpDir))
+	require.NoError(t, err)
+
+	i, err := mock.NewManager().NewInput(conf)
+	require.NoError(t, err)
+
+	act := map[string]string{}
+	for range 2 {
+		var tran message.Transaction
+		var open bool
+		select {
+		case tran, open = <-i.TransactionChan():
+			require.True(t, open)
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+
+		res := tran.Payload.Get(0)
+		act[filepath.Base(res.MetaGetStr("path"))] = string(res.AsBytes())
+		require.NoError(t, tran.Ack(context.Background(), nil))
+	}
+
+	assert.Equal(t, map[string]string{
+		"a.csv": `{"id":"1","name":"foo"}`,
+		"b.csv": `{"code":"x","price":"2.5","qty":"3"}`,
+	}, act)
+}
+
 func TestFileDirectoryDeprecated(t *testing.T) {
 	tmpDir := t.TempDir()
 
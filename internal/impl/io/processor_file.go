@@ -1,40 +1,378 @@
 package io
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"io/fs"
+	"math"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"github.com/dustin/go-humanize"
 
 	"github.com/warpstreamlabs/bento/internal/component"
+	"github.com/warpstreamlabs/bento/internal/component/interop"
+	"github.com/warpstreamlabs/bento/internal/filepath/ifs"
+	"github.com/warpstreamlabs/bento/public/bloblang"
 	"github.com/warpstreamlabs/bento/public/service"
 )
 
 const (
 	fileProcessorFieldOperation = "operation"
 	fileProcessorFieldPath      = "path"
+	fileProcessorFieldPaths     = "paths"
 	fileProcessorFieldDest      = "destination_path"
+	fileProcessorFieldTree      = "preserve_tree"
+	fileProcessorFieldBase      = "source_base"
 	fileProcessorFieldScanner   = "scanner"
+	fileProcessorFieldCodec     = "codec"
+	fileProcessorFieldScanWait  = "scanner_timeout"
+	fileProcessorFieldLimit     = "limit"
+	fileProcessorFieldCollapse  = "collapse"
+	fileProcessorFieldOffsets   = "byte_offsets"
+	fileProcessorFieldPartMeta  = "part_metadata"
+	fileProcessorFieldOnEmpty   = "on_empty"
+	fileProcessorFieldChunkSize = "byte_chunk_size"
+	fileProcessorFieldReadBuf   = "read_buffer_size"
+	fileProcessorFieldSplitRe   = "split_regex"
+	fileProcessorFieldOutCodec  = "output_codec"
+	fileProcessorFieldFraming   = "framing"
+	fileProcessorFieldSymlinks  = "follow_symlinks"
+	fileProcessorFieldOffCache  = "offset_cache"
+	fileProcessorFieldOffKey    = "offset_key"
+	fileProcessorFieldOffCommit = "commit_offsets"
+	fileProcessorFieldEncode    = "encode"
+	fileProcessorFieldNewline   = "ensure_trailing_newline"
+	fileProcessorFieldBOM       = "bom"
+	fileProcessorFieldValidate  = "validate"
+	fileProcessorFieldValCheck  = "validate_check"
+	fileProcessorFieldDecode    = "decode"
+	fileProcessorFieldPattern   = "pattern"
+	fileProcessorFieldPredicate = "predicate"
+	fileProcessorFieldEmit      = "emit"
+	fileProcessorFieldOnDelFail = "on_source_delete_failure"
+	fileProcessorFieldSkipSame  = "skip_unchanged"
+	fileProcessorFieldContentID = "content_addressed"
+	fileProcessorFieldFallback  = "fallback_path"
+	fileProcessorFieldSocket    = "socket"
+	fileProcessorFieldStageDir  = "stage_dir"
+	fileProcessorFieldSyncPol   = "sync_policy"
+	fileProcessorFieldSyncEvery = "sync_interval"
+	fileProcessorFieldFileMode  = "file_mode"
+	fileProcessorFieldDirMode   = "dir_mode"
+	fileProcessorFieldAudit     = "audit_output"
+	fileProcessorFieldDeadEnd   = "dead_letter"
+	fileProcessorFieldVerify    = "verify_permissions"
+	fileProcessorFieldErrMode   = "error_mode"
+	fileProcessorFieldAllOrNone = "all_or_nothing"
+	fileProcessorFieldWorkers   = "workers"
+	fileProcessorFieldDirectIO  = "direct_io"
+	fileProcessorFieldChkSpace  = "check_space"
+	fileProcessorFieldMinSpace  = "min_free_space"
+	fileProcessorFieldOnSuccess = "success_processors"
+	fileProcessorFieldExpected  = "expected_checksum"
+	fileProcessorFieldAlgorithm = "checksum_algorithm"
+	fileProcessorFieldMismatch  = "fail_on_mismatch"
+	fileProcessorFieldFailOnErr = "fail_on_error"
+	fileProcessorFieldRetries   = "rename_retries"
+	fileProcessorFieldRetryWait = "rename_retry_delay"
+	fileProcessorFieldSamePath  = "same_path_behavior"
+	fileProcessorFieldStaleAge  = "stale_temp_file_age"
+	fileProcessorFieldConflict  = "on_conflict"
+	fileProcessorFieldThreshold = "size_threshold"
+	fileProcessorFieldInterval  = "poll_interval"
+	fileProcessorFieldStep      = "step"
+	fileProcessorFieldVersions  = "keep_versions"
+	fileProcessorFieldRecursive = "recursive"
+	fileProcessorFieldDiffLines = "max_diff_lines"
 
 	// Operation types
-	fileProcessorOpRead   = "read"
-	fileProcessorOpWrite  = "write"
-	fileProcessorOpDelete = "delete"
-	fileProcessorOpMove   = "move"
-	fileProcessorOpRename = "rename"
-	fileProcessorOpStat   = "stat"
+	fileProcessorOpRead    = "read"
+	fileProcessorOpWrite   = "write"
+	fileProcessorOpDelete  = "delete"
+	fileProcessorOpMove    = "move"
+	fileProcessorOpRename  = "rename"
+	fileProcessorOpStat    = "stat"
+	fileProcessorOpMktemp  = "mktemp"
+	fileProcessorOpFilter  = "filter"
+	fileProcessorOpVerify  = "verify"
+	fileProcessorOpTree    = "tree_checksum"
+	fileProcessorOpPatch   = "patch"
+	fileProcessorOpReflow  = "reflow"
+	fileProcessorOpRestore = "restore"
+	fileProcessorOpWatch   = "watch_size"
+	fileProcessorOpCounter = "counter"
+	fileProcessorOpSwap    = "swap"
+	fileProcessorOpDelta   = "delta"
+	fileProcessorOpGetACL  = "getfacl"
+	fileProcessorOpSetACL  = "setfacl"
+	fileProcessorOpPublish = "publish_versioned"
+	fileProcessorOpAppend  = "append"
+	fileProcessorOpCopy    = "copy"
+	fileProcessorOpHash    = "checksum"
+	fileProcessorOpList    = "list"
+)
+
+// fileProcessorSidecarSuffix is appended to the path of a file in order to
+// locate its metadata sidecar for the restore operation.
+const fileProcessorSidecarSuffix = ".meta.json"
+
+// Emit modes for the write operation
+const (
+	fileProcessorEmitInput   = "input"
+	fileProcessorEmitReceipt = "receipt"
+)
+
+// Handling of failures of individual paths for operations on a list of paths
+const (
+	fileProcessorErrModeFailFast = "fail_fast"
+	fileProcessorErrModeCollect  = "collect"
+)
+
+// Behaviours when a move fails to delete its source file
+const (
+	fileProcessorOnDelFailWarn     = "warn"
+	fileProcessorOnDelFailError    = "error"
+	fileProcessorOnDelFailMetadata = "metadata"
+)
+
+// Behaviours when a read yields no records
+const (
+	fileProcessorOnEmptyMetadata = "metadata"
+	fileProcessorOnEmptySkip     = "skip"
+	fileProcessorOnEmptyError    = "error"
+)
+
+// Behaviours when a move or rename has identical source and destination paths
+const (
+	fileProcessorSamePathSkip  = "skip"
+	fileProcessorSamePathError = "error"
+)
+
+// Encodings of file content for the write and read operations
+const (
+	fileProcessorEncNone      = "none"
+	fileProcessorEncBase64    = "base64"
+	fileProcessorEncBase64URL = "base64url"
+	fileProcessorEncHex       = "hex"
+)
+
+// Validations of content before the write operation
+const (
+	fileProcessorValidateNone = "none"
+	fileProcessorValidateJSON = "json"
+	fileProcessorValidateXML  = "xml"
+)
+
+// validateXML returns an error unless content is a well formed XML document
+// with a root element.
+func validateXML(content []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	var hasRoot bool
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			hasRoot = true
+		}
+	}
+	if !hasRoot {
+		return errors.New("document has no root element")
+	}
+	return nil
+}
+
+// fileProcessorEncode returns content encoded with the named encoding.
+func fileProcessorEncode(encoding string, content []byte) []byte {
+	switch encoding {
+	case fileProcessorEncBase64:
+		return base64.StdEncoding.AppendEncode(nil, content)
+	case fileProcessorEncBase64URL:
+		return base64.URLEncoding.AppendEncode(nil, content)
+	case fileProcessorEncHex:
+		return hex.AppendEncode(nil, content)
+	}
+	return content
+}
+
+// fileProcessorDecoder returns a reader that decodes the content of r with
+// the named encoding.
+func fileProcessorDecoder(encoding string, r io.Reader) io.Reader {
+	switch encoding {
+	case fileProcessorEncBase64:
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case fileProcessorEncBase64URL:
+		return base64.NewDecoder(base64.URLEncoding, r)
+	case fileProcessorEncHex:
+		return hex.NewDecoder(r)
+	}
+	return r
+}
+
+// Handling of byte order marks for the read and write operations
+const (
+	fileProcessorBOMPreserve = "preserve"
+	fileProcessorBOMStrip    = "strip"
+	fileProcessorBOMAdd      = "add"
+)
+
+// fileProcessorBOMs are the byte order marks removed by the strip option.
+var fileProcessorBOMs = [][]byte{
+	{0xEF, 0xBB, 0xBF}, // UTF-8
+	{0xFF, 0xFE},       // UTF-16 little-endian
+	{0xFE, 0xFF},       // UTF-16 big-endian
+}
+
+// stripBOM returns a reader of r with any leading byte order mark removed,
+// along with the number of bytes that were removed.
+func stripBOM(r io.Reader) (io.Reader, int64) {
+	br := bufio.NewReader(r)
+	lead, _ := br.Peek(3)
+	for _, bom := range fileProcessorBOMs {
+		if bytes.HasPrefix(lead, bom) {
+			n, _ := br.Discard(len(bom))
+			return br, int64(n)
+		}
+	}
+	return br, 0
+}
+
+// Behaviours when a hunk of a patch does not apply
+const (
+	fileProcessorOnConflictError = "error"
+	fileProcessorOnConflictSkip  = "skip"
+)
+
+// Checksum algorithms for the checksum and verify operations
+const (
+	fileProcessorAlgoMD5    = "md5"
+	fileProcessorAlgoSHA1   = "sha1"
+	fileProcessorAlgoSHA256 = "sha256"
+	fileProcessorAlgoSHA512 = "sha512"
+	fileProcessorAlgoCRC32  = "crc32"
+)
+
+// fileProcessorNewHash returns a new hash for the named checksum algorithm.
+func fileProcessorNewHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case fileProcessorAlgoMD5:
+		return md5.New(), nil
+	case fileProcessorAlgoSHA1:
+		return sha1.New(), nil
+	case fileProcessorAlgoSHA256:
+		return sha256.New(), nil
+	case fileProcessorAlgoSHA512:
+		return sha512.New(), nil
+	case fileProcessorAlgoCRC32:
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("unrecognised checksum algorithm: %v", algorithm)
+}
+
+const (
+	fileProcessorSyncNone     = "none"
+	fileProcessorSyncEach     = "each"
+	fileProcessorSyncInterval = "interval"
 )
 
+// fileProcessorDestOps are the operations that require a destination_path.
+var fileProcessorDestOps = []string{fileProcessorOpMove, fileProcessorOpCopy, fileProcessorOpRename, fileProcessorOpFilter, fileProcessorOpReflow, fileProcessorOpSwap}
+
+// fileProcessorCodecs are the record framings supported by the codec and
+// output_codec fields.
+var fileProcessorCodecs = map[string]string{
+	"lines":                     "Records are delimited by newlines.",
+	"length_prefixed_uint16_be": "Each record is prefixed by its length in bytes as a big-endian uint16.",
+	"length_prefixed_uint32_be": "Each record is prefixed by its length in bytes as a big-endian uint32.",
+	"length_prefixed_uint64_be": "Each record is prefixed by its length in bytes as a big-endian uint64.",
+	"netstring":                 "Records are encoded as [netstrings](https://cr.yp.to/proto/netstrings.txt).",
+}
+
+// fileProcessorLengthPrefixWidths are the widths in bytes of the length prefix
+// of each length prefixed codec.
+var fileProcessorLengthPrefixWidths = map[string]int{
+	"length_prefixed_uint16_be": 2,
+	"length_prefixed_uint32_be": 4,
+	"length_prefixed_uint64_be": 8,
+}
+
+// fileProcessorReadOps are the operations that read records from 'path'.
+var fileProcessorReadOps = []string{fileProcessorOpRead, fileProcessorOpReflow}
+
+// fileProcessorContentOps are the operations that write the message content,
+// prepared by writableContent, to a file.
+var fileProcessorContentOps = []string{fileProcessorOpWrite, fileProcessorOpAppend, fileProcessorOpPublish}
+
+// fileProcessorContentOpsErr returns an error stating that the named field is
+// only supported by the operations listed in fileProcessorContentOps.
+func fileProcessorContentOpsErr(field string) error {
+	return fmt.Errorf("%v is only supported by the %v, %v and %v operations", field, fileProcessorOpWrite, fileProcessorOpAppend, fileProcessorOpPublish)
+}
+
+// fileProcessorReadFramings are the fields that frame records for the read
+// operation, exactly one of which must be set.
+var fileProcessorReadFramings = []string{fileProcessorFieldScanner, fileProcessorFieldCodec, fileProcessorFieldChunkSize, fileProcessorFieldSplitRe}
+
+func fileProcessorOpRequiresDest(op string) bool {
+	return slices.Contains(fileProcessorDestOps, op)
+}
+
+// quotedList renders values as a human readable list of quoted strings, e.g.
+// 'a', 'b' or 'c'.
+func quotedList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
+
+// bloblangList renders values as a Bloblang array literal of strings.
+func bloblangList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = `"` + v + `"`
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
 func fileProcessorSpec() *service.ConfigSpec {
 	return service.NewConfigSpec().
 		Categories("Local").
-		Summary(fmt.Sprintf(`Performs operations (%s, %s, %s, %s, %s, %s) on files.`, fileProcessorOpRead, fileProcessorOpWrite, fileProcessorOpDelete, fileProcessorOpMove, fileProcessorOpRename, fileProcessorOpStat)).
+		Summary(fmt.Sprintf(`Performs operations (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s) on files.`, fileProcessorOpRead, fileProcessorOpWrite, fileProcessorOpDelete, fileProcessorOpMove, fileProcessorOpRename, fileProcessorOpStat, fileProcessorOpMktemp, fileProcessorOpFilter, fileProcessorOpVerify, fileProcessorOpTree, fileProcessorOpPatch, fileProcessorOpReflow, fileProcessorOpRestore, fileProcessorOpWatch, fileProcessorOpCounter, fileProcessorOpSwap, fileProcessorOpDelta, fileProcessorOpGetACL, fileProcessorOpSetACL, fileProcessorOpPublish, fileProcessorOpAppend, fileProcessorOpCopy, fileProcessorOpHash, fileProcessorOpList)).
 		Description(`
 This processor allows you to perform various file operations based on message content. The operation is specified by the 'operation' field, and paths can be dynamically generated using interpolation functions.
 
@@ -42,10 +380,28 @@ This processor allows you to perform various file operations based on message co
 
 - **read**: Read content at 'path' into the message
 - **write**: Write message content to 'path'
-- **delete**: Delete file at 'path'
+- **append**: Append message content to the end of the file at 'path', creating it and its parent directories when they do not exist. Content is prepared in the same way as 'write', except that a 'bom' is never added. Unlike 'write' this is not atomic, as the file is modified in place, and so readers may observe a partially appended message and an interrupted append may leave one behind.
+- **delete**: Delete file at 'path', or every file in the list returned by the 'paths' mapping
 - **move**: Move a file at 'path' to 'destination_path'
+- **copy**: Copy a file at 'path' to 'destination_path', leaving the source in place. The destination is written atomically and the 'file_dest_path' metadata field is set to it.
 - **rename**: Rename/move a file at 'path' to 'destination_path' using [os.Rename](https://pkg.go.dev/os#Rename).
 - **stat**: Get file information from 'path' without reading content
+- **list**: List the entries of the directory at 'path', emitting one message per entry with the path of the entry as its content. Entries are listed in lexical order, and when 'recursive' is enabled the contents of each subdirectory follow the subdirectory itself. Symbolic links are listed but not followed, and an empty directory results in no messages.
+- **mktemp**: Create a new, uniquely named file within the directory 'path' using 'pattern' (see [os.CreateTemp](https://pkg.go.dev/os#CreateTemp)) and write message content to it. The 'file_path' metadata field is set to the path of the created file.
+- **filter**: Stream the lines of the file at 'path' through the 'predicate' mapping and atomically write the lines that pass to 'destination_path'. The file is processed a line at a time and so memory usage is bounded regardless of file size. The 'file_lines_kept' and 'file_lines_dropped' metadata fields are set on the resulting message.
+- **checksum**: Compute the checksum of the file at 'path' using 'checksum_algorithm', streaming the file without reading it into memory. The 'file_checksum' metadata field is set to the hex encoded checksum and 'file_checksum_algorithm' to the algorithm used. The message content is left unchanged.
+- **verify**: Compute the checksum of the file at 'path' using 'checksum_algorithm' and compare it against 'expected_checksum'. The 'file_checksum' metadata field is set to the hex encoded checksum and 'file_checksum_valid' is set to whether it matched, and the operation fails on a mismatch when 'fail_on_mismatch' is enabled. The message content is left unchanged.
+- **tree_checksum**: Walk the directory at 'path', compute the checksum of each regular file within it using 'checksum_algorithm', and combine them into a single digest ordered by relative path. The hex encoded digest is set to the 'dir_checksum' metadata field, and changes whenever a file beneath the directory is added, removed, renamed or modified. The message content is left unchanged.
+- **patch**: Apply the unified diff contained in the message to the file at 'path' and write the result atomically. Hunks that are not found at their stated line are searched for nearby, and the handling of hunks that cannot be found at all is determined by 'on_conflict'. The 'file_patch_hunks_applied' and 'file_patch_hunks_failed' metadata fields are set on the resulting message.
+- **reflow**: Stream the records of the file at 'path', framed in the same way as 'read', and atomically write them to 'destination_path' framed by 'output_codec'. Structured records, such as those produced by the [`+"`csv`"+` scanner](/docs/components/scanners/csv), are written as JSON documents, and so a CSV file can be converted to JSON lines in a single operation. The 'file_records_written' metadata field is set on the resulting message.
+- **restore**: Read the entire file at 'path' into the message along with its metadata sidecar, a JSON object of metadata keys and values stored alongside it with the suffix '.meta.json', such that a message can be reconstructed with its original metadata. When the sidecar does not exist only the content is restored.
+- **watch_size**: Poll the size of the file at 'path' every 'poll_interval' until it reaches 'size_threshold', and then emit the message with the file metadata of that moment, including its current 'file_size'. A file that does not yet exist is treated as empty. This allows size triggered rotation or export workflows, for example by driving the processor with a [`+"`generate`"+` input](/docs/components/inputs/generate). The message content is left unchanged.
+- **counter**: Read the integer held in the file at 'path', add 'step' to it and atomically write the result back, setting the new value to the 'file_counter_value' metadata field. A file that does not yet exist is treated as holding zero. Increments made by this processor are serialised, which provides a persistent monotonic counter for generating sequential IDs or file names, but the file must not be modified by other processes at the same time. The message content is left unchanged.
+- **swap**: Exchange the files at 'path' and 'destination_path', both of which must exist. On Linux this uses `+"`renameat2`"+` with `+"`RENAME_EXCHANGE`"+` so that readers of either path always observe one complete file or the other. On other platforms, and on filesystems that do not support the exchange, the files are swapped with three renames via a temporary name, during which 'path' briefly does not exist. Custom filesystems are not supported, as the exchange is made on the host filesystem.
+- **delta**: Compute a unified diff, with three lines of context, that transforms the file at 'path' into the message content. The diff replaces the message content, or when 'destination_path' is set it is atomically written there and the message content is left unchanged. A file that does not yet exist is treated as empty, and the diff is empty when the contents are identical. The resulting diff can be applied with the 'patch' operation. The 'file_delta_changed', 'file_delta_lines_added' and 'file_delta_lines_removed' metadata fields are set on the resulting message.
+- **getfacl**: Read the POSIX access ACL of the file at 'path' into the 'file_acl' metadata field, an array of entries in the order listed by `+"`getfacl`"+`, each an object with a 'tag' of `+"`user`"+`, `+"`group`"+`, `+"`mask`"+` or `+"`other`"+`, 'perms' such as `+"`rw-`"+` and, for entries of named users and groups, a numeric 'id'. Files without an extended ACL yield the entries equivalent to their permission bits. ACLs are only supported on Linux. The message content is left unchanged.
+- **setfacl**: Replace the POSIX access ACL of the file at 'path' with the array of entries contained in the message, in the form produced by 'getfacl'. ACLs with entries for named users or groups must also contain a 'mask' entry. ACLs are only supported on Linux.
+- **publish_versioned**: Write message content, prepared in the same way as 'write', to a new version alongside 'path' named with the suffix '.v' followed by the UTC time of the write, such as `+"`data.v20240101T120000.000000000Z`"+`, and then atomically replace the symbolic link at 'path' with one pointing to it. Readers that follow the link always observe a complete version, and a publish can be rolled back by pointing the link at an earlier version. All but the newest 'keep_versions' versions are then removed. The 'file_version_path' and 'file_versions_pruned' metadata fields are set on the resulting message. Creating symbolic links on Windows requires developer mode or administrator privileges. Custom filesystems that are unable to create symbolic links are not supported.
 
 ### move vs rename
 You may want to use the rename operation instead of using move and therefore avoid copying bytes.
@@ -53,42 +409,392 @@ However you should be aware that there are OS-specific restrictions with this ap
 
 ### Metadata
 
-When reading or getting file info (stat), this processor adds the following metadata fields:
+When reading, getting file info (stat), listing a directory (list) or creating a temporary file (mktemp), this processor adds the following metadata fields:
 
 `+"```text"+`
-- file_path: The path of the file
-- file_size: The size of the file in bytes
-- file_mod_time_unix: File modification time as Unix timestamp
-- file_mod_time: File modification time in RFC3339 format
-- file_name: The name of the file
-- file_is_dir: Whether the file is a directory (true/false)
-- file_mode: File permissions and mode
-`+"```"+``).
+- file_path: The path of the file (string)
+- file_size: The size of the file in bytes (integer)
+- file_mod_time_unix: File modification time as Unix timestamp (integer)
+- file_mod_time: File modification time in RFC3339 format (string)
+- file_name: The name of the file (string)
+- file_is_dir: Whether the file is a directory (boolean)
+- file_mode: File permissions and mode (string)
+- file_mode_octal: File permissions in octal notation including the setuid, setgid and sticky bits, such as 0644 or 4755 (string)
+- file_world_writable: Whether the file is writable by all users (boolean)
+- file_setuid: Whether the setuid bit is set (boolean)
+- file_setgid: Whether the setgid bit is set (boolean)
+`+"```"+`
+
+The stat operation additionally adds the fields `+"`file_inode`"+` and `+"`file_device`"+` (integers) on Unix platforms, which identify the underlying file such that two paths with equal values refer to the same file.
+
+Every message emitted by this processor also has the field `+"`file_operation_duration_ms`"+` set to the time taken to perform the operation in milliseconds (integer).
+
+Metadata values are stored with the types listed above, and other metadata fields added by this processor are integers when they represent counts and booleans when they represent flags. The [`+"`metadata`"+` function](/docs/guides/bloblang/functions#metadata) returns these typed values, allowing numeric comparisons such as `+"`metadata(\"file_size\") > 1024`"+`, whereas the [`+"`meta`"+` function](/docs/guides/bloblang/functions#meta) and interpolations return their string representations.`).
 		Fields(
-			service.NewStringEnumField(fileProcessorFieldOperation, fileProcessorOpRead, fileProcessorOpWrite, fileProcessorOpDelete, fileProcessorOpMove, fileProcessorOpRename, fileProcessorOpStat).
+			service.NewStringEnumField(fileProcessorFieldOperation, fileProcessorOpRead, fileProcessorOpWrite, fileProcessorOpDelete, fileProcessorOpMove, fileProcessorOpRename, fileProcessorOpStat, fileProcessorOpMktemp, fileProcessorOpFilter, fileProcessorOpVerify, fileProcessorOpTree, fileProcessorOpPatch, fileProcessorOpReflow, fileProcessorOpRestore, fileProcessorOpWatch, fileProcessorOpCounter, fileProcessorOpSwap, fileProcessorOpDelta, fileProcessorOpGetACL, fileProcessorOpSetACL, fileProcessorOpPublish, fileProcessorOpAppend, fileProcessorOpCopy, fileProcessorOpHash, fileProcessorOpList).
 				Description("The file operation to perform."),
 			service.NewInterpolatedStringField(fileProcessorFieldPath).
-				Description("The path used for reads, writes, deletes and stat. Source path for move and rename.").
+				Description("The path used for reads, writes, appends, deletes, stat, checksum, verify, patch, restore, watch_size, counter, delta, getfacl and setfacl. Symbolic link to the current version for publish_versioned. Directory for tree_checksum and list. Source path for move, copy, rename, filter and reflow. First of the two paths exchanged by swap. Parent directory for mktemp.").
 				Examples(
 					"/tmp/data.txt",
 					"/tmp/${! json(\"document.id\") }.txt",
-				).LintRule(`if this == "" { [ "'path' must be set to a non-empty string" ] }`),
+				).
+				Optional().
+				LintRule(`if this == "" { [ "'path' must be set to a non-empty string" ] }`),
+			service.NewBloblangField(fileProcessorFieldPaths).
+				Description("A [Bloblang mapping](/docs/guides/bloblang/about) that returns an array of paths, which may be used instead of 'path' for the 'delete' and 'write' operations in order to delete many files, or write the message content to many files, in one invocation. The outcome of each deletion is reported in the metadata fields `file_delete_results`, an object mapping each path to `true` when it was deleted or `false` otherwise, and `file_delete_errors`, an object mapping each path that could not be deleted to the error encountered. Likewise the outcome of each write is reported in the metadata fields `file_write_results` and `file_write_errors`. Each file is written atomically, and the fields 'skip_unchanged', 'check_space' and 'emit' are not supported when writing to a list of paths.").
+				Optional().
+				Examples(
+					`root = this.files.map_each(f -> "/tmp/" + f)`,
+				),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldErrMode, map[string]string{
+				fileProcessorErrModeFailFast: "Stop and fail the operation at the first path that cannot be deleted or written, leaving the remaining paths untouched.",
+				fileProcessorErrModeCollect:  "Attempt every path and report each failure in metadata, returning the message rather than failing the operation.",
+			}).
+				Description("How failures of individual paths are handled when deleting or writing a list of 'paths'.").
+				Advanced().
+				Default(fileProcessorErrModeCollect),
+			service.NewIntField(fileProcessorFieldWorkers).
+				Description("The number of paths within a list of 'paths' that are deleted or written concurrently, which can greatly reduce the time taken by large lists on fast storage. The outcome of each path is reported in metadata regardless of the order in which they complete. Writes with 'all_or_nothing' enabled are always performed serially.").
+				Advanced().
+				Default(1),
+			service.NewBoolField(fileProcessorFieldAllOrNone).
+				Description("When writing a list of 'paths', write the content to a temporary file alongside every path before any of them are renamed into place, and fail the operation without modifying any path when one of them cannot be written. A failure to rename a temporary file, which is uncommon once it has been written, can still leave the paths renamed before it updated.").
+				Advanced().
+				Default(false),
 			service.NewInterpolatedStringField(fileProcessorFieldDest).
-				Description("The destination path for 'move' and 'rename' operations.").
+				Description("The destination path for 'move', 'copy', 'rename', 'filter' and 'reflow' operations, the second of the two paths exchanged by 'swap', and optionally the path that 'delta' writes its diff to.").
 				Optional().
 				Examples(
 					"/tmp/backup/${! json(\"document.id\") }.txt",
 				),
+			service.NewBoolField(fileProcessorFieldTree).
+				Description("For the 'move' and 'rename' operations, treat 'destination_path' as a directory and preserve the location of 'path' relative to 'source_base' beneath it, creating any missing directories. For example, with a 'source_base' of `/src` the file `/src/a/b/f.txt` is moved to `a/b/f.txt` within 'destination_path'. Paths that are not within 'source_base' result in an error.").
+				Advanced().
+				Default(false),
+			service.NewInterpolatedStringField(fileProcessorFieldBase).
+				Description("The base directory of source paths when 'preserve_tree' is enabled.").
+				Advanced().
+				Optional().
+				Examples("/var/data/incoming"),
+			service.NewInterpolatedStringField(fileProcessorFieldPattern).
+				Description("The file name pattern for the 'mktemp' operation. The last '*' is replaced by a random string, if the pattern does not contain a '*' the random string is appended.").
+				Default("").
+				Examples(
+					"upload-*.json",
+				),
+			service.NewBloblangField(fileProcessorFieldPredicate).
+				Description("A [Bloblang mapping](/docs/guides/bloblang/about) executed against each line for the 'filter' operation, which must return a boolean indicating whether the line should be kept. Metadata of the input message is available to the mapping.").
+				Optional().
+				Examples(
+					`content().string().contains("ERROR")`,
+					`!content().string().has_prefix("#")`,
+				),
+			service.NewInterpolatedStringField(fileProcessorFieldExpected).
+				Description("The hex encoded checksum that the file must match for the 'verify' operation, which is compared case insensitively.").
+				Optional().
+				Examples(
+					`${! meta("checksum") }`,
+				),
+			service.NewStringEnumField(fileProcessorFieldAlgorithm, fileProcessorAlgoMD5, fileProcessorAlgoSHA1, fileProcessorAlgoSHA256, fileProcessorAlgoSHA512, fileProcessorAlgoCRC32).
+				Description("The algorithm used to compute checksums for the 'checksum', 'verify' and 'tree_checksum' operations, and for naming files written with `content_addressed`. The IEEE `crc32` checksum is the fastest, but is only suitable for detecting accidental corruption.").
+				Advanced().
+				Default(fileProcessorAlgoSHA256),
+			service.NewBoolField(fileProcessorFieldMismatch).
+				Description("Whether the 'verify' operation should fail when the checksum does not match 'expected_checksum'. When `false` a mismatch is only reported in the `file_checksum_valid` metadata field.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldFailOnErr).
+				Description("Whether the 'getfacl' and 'setfacl' operations should fail when the ACL cannot be read or written, including on platforms and filesystems without ACL support. When `false` the error is instead reported in the `file_acl_error` metadata field and the message is otherwise left unchanged.").
+				Advanced().
+				Default(true),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldEmit, map[string]string{
+				fileProcessorEmitInput:   "Emit the original message unchanged.",
+				fileProcessorEmitReceipt: "Replace the message content with a JSON receipt describing the write, containing the fields `path`, `bytes`, `checksum` (a hex encoded SHA-256 digest of the content written) and `timestamp` (RFC3339 format).",
+			}).
+				Description("The message to emit after a successful 'write' operation.").
+				Advanced().
+				Default(fileProcessorEmitInput),
+			service.NewBoolField(fileProcessorFieldSkipSame).
+				Description("For the 'write' operation, skip writing when the file at 'path' already holds identical content, leaving its modification time untouched. Existing files of the same size are compared by SHA-256 checksum. When enabled the metadata field `file_write_skipped` is set to `true` or `false`.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldContentID).
+				Description("For the 'write' operation, treat 'path' as a directory and name the written file after the hex encoded checksum of its content, computed with `checksum_algorithm`. When a file of that name already exists the write is skipped. The metadata field `file_path` is set to the resulting path and `file_write_skipped` is set to `true` or `false`.").
+				Advanced().
+				Default(false),
+			service.NewInterpolatedStringField(fileProcessorFieldFallback).
+				Description("For the 'write' operation, a path to write to instead of 'path' when writing to 'path' fails because the disk is full, a quota is exceeded, the filesystem is read only or permission is denied. Other errors are not retried. When set the metadata field `file_fallback_used` is set to `true` or `false`, and `file_path` is set to the path that was written.").
+				Advanced().
+				Optional().
+				Examples(
+					"/mnt/spill/${! @kafka_key }.txt",
+				),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldValidate, map[string]string{
+				fileProcessorValidateNone: "Content is not validated.",
+				fileProcessorValidateJSON: "Content must be a valid JSON document.",
+				fileProcessorValidateXML:  "Content must be a well formed XML document with a root element.",
+			}).
+				Description("For the 'write', 'append' and 'publish_versioned' operations, check that the message content is valid in this format before it is written. Content that fails validation results in an error and the file is left untouched.").
+				Advanced().
+				Default(fileProcessorValidateNone),
+			service.NewBloblangField(fileProcessorFieldValCheck).
+				Description("For the 'write', 'append' and 'publish_versioned' operations, a [Bloblang mapping](/docs/guides/bloblang/about) executed against the message that must return a boolean, where `false` results in an error and the file is left untouched. This is checked in addition to 'validate'.").
+				Advanced().
+				Optional().
+				Examples(
+					`this.exists("id")`,
+					`content().length() > 0`,
+				),
+			service.NewStringEnumField(fileProcessorFieldEncode, fileProcessorEncNone, fileProcessorEncBase64, fileProcessorEncBase64URL, fileProcessorEncHex).
+				Description("For the 'write', 'append' and 'publish_versioned' operations, encode the message content with this encoding before it is written. Checksums, size checks and comparisons made by 'skip_unchanged' apply to the encoded content. Files written this way can be read back with 'decode'.").
+				Advanced().
+				Default(fileProcessorEncNone),
+			service.NewBoolField(fileProcessorFieldNewline).
+				Description("For the 'write', 'append' and 'publish_versioned' operations, append a newline to the content written when it does not already end with one, which is convenient when producing files consumed by line based tools. The newline is appended after 'encode' is applied.").
+				Advanced().
+				Default(false),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldBOM, map[string]string{
+				fileProcessorBOMPreserve: "Read and write content as it is.",
+				fileProcessorBOMStrip:    "For the 'read' and 'reflow' operations, remove a leading UTF-8 or UTF-16 byte order mark from the file before it is split into records.",
+				fileProcessorBOMAdd:      "For the 'write' operation, prepend a UTF-8 byte order mark to content that does not already begin with one.",
+			}).
+				Description("How byte order marks at the beginning of files are handled.").
+				Advanced().
+				Default(fileProcessorBOMPreserve),
+			service.NewStringField(fileProcessorFieldThreshold).
+				Description("The size that the file must reach for the 'watch_size' operation to emit, such as `100MB` or `1GiB`.").
+				Optional().
+				Examples("100MB"),
+			service.NewDurationField(fileProcessorFieldInterval).
+				Description("The interval at which the size of the file is checked for the 'watch_size' operation.").
+				Advanced().
+				Default("1s"),
+			service.NewIntField(fileProcessorFieldStep).
+				Description("The amount added to the value of the file for each 'counter' operation, which may be negative.").
+				Advanced().
+				Default(1),
+			service.NewIntField(fileProcessorFieldVersions).
+				Description("The number of versions kept by the 'publish_versioned' operation, including the one just published. When zero all versions are kept.").
+				Advanced().
+				Default(5),
+			service.NewIntField(fileProcessorFieldDiffLines).
+				Description("The maximum number of lines of either the file or the message content that the 'delta' operation compares, beyond which the operation fails. The time taken to compute a diff grows with the product of the number of lines and the number of differences between them, and so this bounds the cost of diffing inputs that have little in common. When zero there is no limit.").
+				Advanced().
+				Default(10000),
+			service.NewBoolField(fileProcessorFieldRecursive).
+				Description("Whether the 'list' operation also lists the entries of every subdirectory beneath 'path'.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldDirectIO).
+				Description("For the 'write' operation, open the file with `O_DIRECT` in order to bypass the operating system page cache, which avoids evicting other cached data when writing large volumes. This is only supported on Linux, on other platforms and on filesystems that do not support `O_DIRECT` (such as tmpfs) writes fall back to regular buffered writes.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldSocket).
+				Description("For the 'write' operation, when 'path' is a unix domain socket connect to it and write the content to the connection instead of replacing it with a regular file. Paths that are not sockets are written to as usual.").
+				Advanced().
+				Default(false),
+			service.NewStringField(fileProcessorFieldStageDir).
+				Description("For the 'write' operation, a directory on fast local storage where content is first written in full before being copied to 'path' and removed. This allows messages to be persisted locally at local disk speed, with the slower transfer to a network filesystem performed as a distinct step that still replaces 'path' atomically. The metadata fields `file_write_duration_ms` and `file_transfer_duration_ms` are set to the time in milliseconds taken by each step.").
+				Advanced().
+				Optional().
+				Example("/var/lib/bento/stage"),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldSyncPol, map[string]string{
+				fileProcessorSyncNone:     "Files are not flushed, and writes may be lost if the machine fails shortly after they are acknowledged.",
+				fileProcessorSyncEach:     "Each file is flushed, along with the directory containing it, before its write is acknowledged.",
+				fileProcessorSyncInterval: "Files written within each 'sync_interval' are flushed together, and their writes are acknowledged once the flush completes. This amortises the cost of flushing across concurrent writes at the expense of latency.",
+			}).
+				Description("Whether files written atomically by this processor are flushed to stable storage with `fsync` before the operation completes, and thereby before the message is acknowledged.").
+				Advanced().
+				Default(fileProcessorSyncNone),
+			service.NewDurationField(fileProcessorFieldSyncEvery).
+				Description("The interval at which written files are flushed when 'sync_policy' is `interval`.").
+				Advanced().
+				Default("100ms"),
+			service.NewStringField(fileProcessorFieldFileMode).
+				Description("The permission bits, as an octal string, of files created by operations such as 'write', 'append', 'move' and 'copy'. The umask of the process is applied to them, and files created by 'mktemp' are always only accessible by their owner.").
+				Advanced().
+				Default("0666").
+				Example("0600").
+				LintRule(fileModeLintRule(fileProcessorFieldFileMode)),
+			service.NewStringField(fileProcessorFieldDirMode).
+				Description("The permission bits, as an octal string, of any missing parent directories created by this processor. The umask of the process is applied to them.").
+				Advanced().
+				Default("0777").
+				Example("0700").
+				LintRule(fileModeLintRule(fileProcessorFieldDirMode)),
+			service.NewBoolField(fileProcessorFieldChkSpace).
+				Description("For the 'write' operation, query the space available on the filesystem of 'path' before writing and set it in bytes to the metadata field `file_space_available`. This is supported on Linux, macOS, FreeBSD and Windows.").
+				Advanced().
+				Default(false),
+			service.NewStringField(fileProcessorFieldMinSpace).
+				Description("When 'check_space' is enabled, refuse to perform a write that would leave less than this amount of space available, such as `500MB` or `10GiB`. Refused writes fail without modifying the file, allowing messages to be routed elsewhere.").
+				Advanced().
+				Default("0"),
+			service.NewIntField(fileProcessorFieldRetries).
+				Description("The maximum number of times to retry the final rename of a temporary file onto its destination when it fails because the destination is momentarily busy, such as a sharing violation on Windows or a busy text file on Unix. This applies to every operation that replaces files atomically via a temporary file, including 'write' (also with 'paths'), 'move', 'copy', 'filter', 'reflow', 'patch', 'counter', 'delta' and 'publish_versioned'. The temporary file is only removed once all retries are exhausted. The 'rename' and 'swap' operations rename files directly and are not retried.").
+				Advanced().
+				Default(0),
+			service.NewDurationField(fileProcessorFieldRetryWait).
+				Description("The delay between attempts to rename a temporary file onto a busy destination.").
+				Advanced().
+				Default("100ms"),
+			service.NewDurationField(fileProcessorFieldStaleAge).
+				Description("When set, temporary files older than this age that were left behind by interrupted writes are removed during startup. Only files alongside a static 'path' for the 'write' operation, or a static 'destination_path' for the 'move' and 'filter' operations, and files within 'stage_dir', whose names match the temporary file pattern used by this processor are removed. Temporary file names include the process ID and a random suffix, and so cannot collide between processes.").
+				Advanced().
+				Optional().
+				Example("1h"),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldConflict, map[string]string{
+				fileProcessorOnConflictError: "Fail the operation, leaving the file unmodified.",
+				fileProcessorOnConflictSkip:  "Apply the hunks that can be applied and skip the rest.",
+			}).
+				Description("How the 'patch' operation handles hunks that do not apply to the file.").
+				Advanced().
+				Default(fileProcessorOnConflictError),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldSamePath, map[string]string{
+				fileProcessorSamePathSkip:  "Leave the file untouched and emit the message with the metadata field `file_skipped` set to `true`.",
+				fileProcessorSamePathError: "Fail the operation.",
+			}).
+				Description("How to handle a 'move', 'copy' or 'rename' operation where the source and destination resolve to the same path, which would otherwise risk deleting the file after copying it onto itself.").
+				Advanced().
+				Default(fileProcessorSamePathSkip),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldOnDelFail, map[string]string{
+				fileProcessorOnDelFailWarn:     "Log the failure and emit the message as normal, leaving both the source and destination files in place.",
+				fileProcessorOnDelFailError:    "Fail the operation. The destination file will already have been written.",
+				fileProcessorOnDelFailMetadata: "Log the failure and emit the message with the metadata field `file_source_delete_failed` set to `true` so that it can be reconciled downstream.",
+			}).
+				Description("How to handle a 'move' operation that successfully copies the source file but then fails to delete it.").
+				Advanced().
+				Default(fileProcessorOnDelFailWarn),
+			service.NewStringField(fileProcessorFieldAudit).
+				Description("The name of an [output resource](/docs/components/outputs/resource) to send an audit record to for each operation performed, whether it succeeds or fails. Audit records are JSON objects containing the fields `operation`, `path`, `destination_path` (when set), `result` (`success` or `error`), `error` (when the operation failed), `timestamp` (RFC3339 format) and, for the 'read', 'write' and 'mktemp' operations, `bytes`, which for 'write' counts the content once it has been prepared for disk. Failures to deliver an audit record are logged and do not affect the processed message.").
+				Advanced().
+				Optional(),
+			service.NewStringField(fileProcessorFieldDeadEnd).
+				Description("The name of an [output resource](/docs/components/outputs/resource) to route messages to when their operation fails, rather than flagging the error and passing them on. Dead-lettered messages are the original input message with the metadata field `file_error` set to the error encountered, and are dropped from the main flow once delivered. When delivery to the dead letter output fails the message is flagged with the original error as normal.").
+				Advanced().
+				Optional(),
+			service.NewProcessorListField(fileProcessorFieldOnSuccess).
+				Description("A list of processors to apply to the resulting messages only once the operation has succeeded, such as sending a notification or indexing a written file. These processors are skipped when the operation fails, in which case the original message is emitted with the error flagged as normal.").
+				Advanced().
+				Default([]any{}),
+			service.NewBoolField(fileProcessorFieldVerify).
+				Description("Whether to verify during startup that the operation has the access it needs to any static (non-interpolated) 'path' and 'destination_path', failing fast with a config error rather than at the first message. Directories that will be written to are probed by creating and removing a temporary file within them, and existing files that will be read are opened. Paths containing interpolation functions are not checked.").
+				Advanced().
+				Default(false),
 			service.NewScannerField(fileProcessorFieldScanner).
 				Description("The scanner to use for reading files.").
 				Advanced().
 				Optional(),
+			service.NewDurationField(fileProcessorFieldScanWait).
+				Description("The maximum time that the 'scanner' may take to produce each batch of records, after which the read fails with an error. This prevents a malformed file, or a file that never reaches the end of a record such as a named pipe, from stalling the pipeline indefinitely. A scanner that does not observe the cancellation is abandoned and closed once the file is closed.").
+				Advanced().
+				Optional().
+				Example("30s"),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldCodec, fileProcessorCodecs).
+				Description("An alternative to 'scanner' for the 'read' and 'reflow' operations which frames records in formats that scanners do not support. A file that ends with a truncated record results in an error. Fixed-width records can be read with the [`chunker`](/docs/components/scanners/chunker) scanner.").
+				Advanced().
+				Optional(),
+			service.NewStringField(fileProcessorFieldChunkSize).
+				Description("An alternative to 'scanner' and 'codec' for the 'read' and 'reflow' operations which slices the file into chunks of this many bytes regardless of their content, such as `1MB` or `5MiB`. The final chunk contains the remainder of the file and may be smaller. The metadata field `file_chunk_offset` is set to the byte offset of each chunk within the file, which is useful for uploads to object stores that require fixed part sizes.").
+				Advanced().
+				Optional().
+				Example("1MB"),
+			service.NewStringField(fileProcessorFieldReadBuf).
+				Description("For the 'read' and 'reflow' operations, read the file through a buffer of this size, such as `1MiB`, so that records are framed from data fetched in large reads. This reduces the number of round trips made when reading from high latency storage such as network mounts, where a scanner issuing many small reads would otherwise wait on each of them. Local files are cached by the operating system and gain little from this, and so reads are not buffered by default.").
+				Advanced().
+				Optional().
+				Example("1MiB"),
+			service.NewStringField(fileProcessorFieldSplitRe).
+				Description("An alternative to 'scanner' for the 'read' and 'reflow' operations which frames records by splitting the file on each match of a [regular expression](https://github.com/google/re2/wiki/Syntax), which is removed from the records. Any content after the final delimiter is emitted as a record. The expression must not match an empty string. Expressions that can match an unbounded length, such as `\\n+`, or that contain assertions such as `^` or `\\b`, are searched from the start of the pending record each time more of the file is read, which is slow for very long records.").
+				Advanced().
+				Optional().
+				Example(`\r?\n---\r?\n`).
+				Example(`[;|]`),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldOutCodec, fileProcessorCodecs).
+				Description("The framing used to write records to 'destination_path' for the 'reflow' operation.").
+				Advanced().
+				Default("lines"),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldFraming, fileProcessorCodecs).
+				Description("For the 'write', 'append' and 'publish_versioned' operations, frame the message content as a single record with this codec, such as a length prefix, before it is written. Files written this way can be read back with the same 'codec'. Framing cannot be combined with 'ensure_trailing_newline' or a 'bom' of `add`.").
+				Advanced().
+				Optional(),
+			service.NewBoolField(fileProcessorFieldOffsets).
+				Description("For the 'read' operation, set the byte offset within the file at which each record begins to the metadata field `file_byte_offset`, allowing an index of records to be built for seeking directly to them later. For length prefixed and netstring codecs the offset is that of the record's prefix. Offsets are only known when records are framed by 'codec', 'split_regex' or 'byte_chunk_size', and so this cannot be combined with 'scanner' or with 'decode'.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldPartMeta).
+				Description("For the 'read' operation, set the zero based position of each record among those emitted by the read to the metadata field `file_part_index`, and the total number of records emitted to `file_part_count`. When 'limit' or 'offset_cache' is used these only cover the records emitted by the invocation. This cannot be combined with 'collapse'.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldCollapse).
+				Description("For the 'read' operation, frame the file into records as usual but emit them as a single message with the records joined by newlines, rather than a message per record. The number of records is set to the metadata field `file_record_count`. This allows individual reads to yield a whole file without changing the framing used.").
+				Advanced().
+				Default(false),
+			service.NewStringAnnotatedEnumField(fileProcessorFieldOnEmpty, map[string]string{
+				fileProcessorOnEmptyMetadata: "Emit a single message with the metadata of the file and the content of the input message.",
+				fileProcessorOnEmptySkip:     "Emit nothing, dropping the input message.",
+				fileProcessorOnEmptyError:    "Fail the operation.",
+			}).
+				Description("How the 'read' operation handles a file that yields no records, such as an empty file or a file with no content beyond its stored offset.").
+				Advanced().
+				Default(fileProcessorOnEmptyMetadata),
+			service.NewIntField(fileProcessorFieldLimit).
+				Description("The maximum number of records to emit for the 'read' operation, after which the file is closed without reading the remainder. This is useful for sampling or previewing large files. Set to `0` to read all records.").
+				Advanced().
+				Default(0),
+			service.NewStringEnumField(fileProcessorFieldDecode, fileProcessorEncNone, fileProcessorEncBase64, fileProcessorEncBase64URL, fileProcessorEncHex).
+				Description("For the 'read' and 'reflow' operations, decode the content of the file with this encoding before it is split into records. This is the counterpart of 'encode'.").
+				Advanced().
+				Default(fileProcessorEncNone),
+			service.NewStringField(fileProcessorFieldOffCache).
+				Description("The name of a [cache resource](/docs/components/caches/about) used by the 'read' operation to track the byte offset reached within each file, such that subsequent reads of a growing file only emit content appended since the previous read. Reading starts from the beginning again when a file is smaller than its stored offset, such as after it has been truncated or rotated. Writers should append whole records, as a partially written record at the end of the file is emitted as it is. Every message read is given the metadata fields `file_offset_key`, `file_read_offset` and `file_read_end_offset`, which hold the key of the offset and the offsets that the read started from and reached. Unless 'commit_offsets' is enabled the offset is not stored by this processor, and should be stored once the records have been delivered as described for 'commit_offsets'. This field cannot be combined with 'limit'.").
+				Advanced().
+				Optional(),
+			service.NewInterpolatedStringField(fileProcessorFieldOffKey).
+				Description("The key under which the offset of a file is stored in 'offset_cache'. Defaults to the cleaned 'path' of the file.").
+				Advanced().
+				Optional().
+				Example(`${! meta("source") }-offset`),
+			service.NewBoolField(fileProcessorFieldOffCommit).
+				Description("Whether the 'read' operation stores the offset reached in 'offset_cache' itself, as soon as the file has been read. By default the offset is left to be stored in 'offset_cache' once the records have been delivered, by setting `file_read_end_offset` under the key `file_offset_key`. The records of each read are emitted as a single batch, so that storing the offset with a [`cache` output](/docs/components/outputs/cache) that follows the main output within a [`broker`](/docs/components/outputs/broker) using the `fan_out_sequential_fail_fast` pattern makes reads at-least-once. The value stored by the `cache` output can be set with a [`mapping`](/docs/components/processors/mapping) processor of `root = meta(\"file_read_end_offset\").string()`. Processors are unable to observe whether the messages they emit are delivered, and so enabling this field makes reads at-most-once: records that fail downstream, or that are in flight when the process stops, are not read again.").
+				Advanced().
+				Default(false),
+			service.NewBoolField(fileProcessorFieldSymlinks).
+				Description("Whether the 'read' operation may read a file through a symbolic link. When `false` a 'path' that is itself a symbolic link results in an error, which prevents a crafted link from exposing files outside of the intended location. Only the final element of the path is checked, and the check is skipped for custom filesystems that are unable to describe symbolic links.").
+				Advanced().
+				Default(true),
 		).LintRule(`root = match {
-      (this.operation == "` + fileProcessorOpMove + `" || this.operation == "` + fileProcessorOpRename + `") && !this.exists("` + fileProcessorFieldDest + `") => [ "'` + fileProcessorFieldDest + `' must be set when operation is '` + fileProcessorOpMove + `' or '` + fileProcessorOpRename + `'" ],
-      this.operation == "` + fileProcessorOpRead + `" && !this.exists("` + fileProcessorFieldScanner + `") => [ "'` + fileProcessorFieldScanner + `' must be set when operation is '` + fileProcessorOpRead + `'" ],
+      !this.exists("` + fileProcessorFieldPath + `") && !(["` + fileProcessorOpDelete + `", "` + fileProcessorOpWrite + `"].contains(this.operation) && this.exists("` + fileProcessorFieldPaths + `")) => [ "'` + fileProcessorFieldPath + `' must be set" ],
+      this.exists("` + fileProcessorFieldPath + `") && this.exists("` + fileProcessorFieldPaths + `") => [ "only one of '` + fileProcessorFieldPath + `' or '` + fileProcessorFieldPaths + `' may be set" ],
+      ` + bloblangList(fileProcessorDestOps) + `.contains(this.operation) && !this.exists("` + fileProcessorFieldDest + `") => [ "'` + fileProcessorFieldDest + `' must be set when operation is ` + quotedList(fileProcessorDestOps) + `" ],
+      this.operation == "` + fileProcessorOpFilter + `" && !this.exists("` + fileProcessorFieldPredicate + `") => [ "'` + fileProcessorFieldPredicate + `' must be set when operation is '` + fileProcessorOpFilter + `'" ],
+      this.operation == "` + fileProcessorOpVerify + `" && !this.exists("` + fileProcessorFieldExpected + `") => [ "'` + fileProcessorFieldExpected + `' must be set when operation is '` + fileProcessorOpVerify + `'" ],
+      this.operation == "` + fileProcessorOpWatch + `" && !this.exists("` + fileProcessorFieldThreshold + `") => [ "'` + fileProcessorFieldThreshold + `' must be set when operation is '` + fileProcessorOpWatch + `'" ],
+      ` + bloblangList(fileProcessorReadOps) + `.contains(this.operation) && ` + bloblangList(fileProcessorReadFramings) + `.filter(f -> this.exists(f)).length() == 0 => [ "` + quotedList(fileProcessorReadFramings) + ` must be set when operation is ` + quotedList(fileProcessorReadOps) + `" ],
+      ` + bloblangList(fileProcessorReadFramings) + `.filter(f -> this.exists(f)).length() > 1 => [ "only one of ` + quotedList(fileProcessorReadFramings) + ` may be set" ],
     }`)
 }
 
+// fileModeLintRule returns a lint rule that rejects values of the named field
+// that are not octal permission bits.
+func fileModeLintRule(field string) string {
+	return `if !this.re_match("^0?[0-7]{3}$") { [ "'` + field + `' must be an octal string of permission bits such as \"0640\"" ] }`
+}
+
+// fileModeFromParsed parses the octal permission bits of the named field.
+func fileModeFromParsed(pConf *service.ParsedConfig, field string) (fs.FileMode, error) {
+	str, err := pConf.FieldString(field)
+	if err != nil {
+		return 0, err
+	}
+	mode, err := strconv.ParseUint(str, 8, 32)
+	if err != nil || mode > uint64(fs.ModePerm) {
+		return 0, fmt.Errorf("%v must be an octal string of permission bits, got '%v'", field, str)
+	}
+	return fs.FileMode(mode), nil
+}
+
 func init() {
 	err := service.RegisterProcessor("file", fileProcessorSpec(),
 		func(pConf *service.ParsedConfig, res *service.Resources) (service.Processor, error) {
@@ -104,240 +810,3110 @@ func init() {
 type fileProcessorConfig struct {
 	Operation       string
 	Path            *service.InterpolatedString
+	Paths           *bloblang.Executor
+	ErrorMode       string
+	AllOrNothing    bool
+	Workers         int
 	DestinationPath *service.InterpolatedString
+	PreserveTree    bool
+	SourceBase      *service.InterpolatedString
+	Pattern         *service.InterpolatedString
+	Predicate       *bloblang.Executor
+	Limit           int
+	Collapse        bool
+	ByteOffsets     bool
+	PartMetadata    bool
+	OnEmpty         string
+	ScannerTimeout  time.Duration
+	OutputCodec     string
+	Framing         string
+	ChunkSize       int
+	ReadBufferSize  int
+	SplitRegex      *regexp.Regexp
+	FollowSymlinks  bool
+	OffsetCache     string
+	OffsetKey       *service.InterpolatedString
+	CommitOffsets   bool
+	Decode          string
+	Encode          string
+	EnsureNewline   bool
+	BOM             string
+	Validate        string
+	ValidateCheck   *bloblang.Executor
+	Expected        *service.InterpolatedString
+	Algorithm       string
+	FailOnMismatch  bool
+	FailOnACLError  bool
+	Emit            string
+	OnDeleteFailure string
+	SamePath        string
+	OnConflict      string
+	SkipUnchanged   bool
+	ContentAddress  bool
+	FallbackPath    *service.InterpolatedString
+	Socket          bool
+	StageDir        string
+	SyncPolicy      string
+	SyncInterval    time.Duration
+	FileMode        fs.FileMode
+	DirMode         fs.FileMode
+	KeepVersions    int
+	Recursive       bool
+	MaxDiffLines    int
+	DirectIO        bool
+	CheckSpace      bool
+	MinFreeSpace    uint64
+	SizeThreshold   int64
+	PollInterval    time.Duration
+	Step            int64
+	RenameRetries   int
+	RenameDelay     time.Duration
+	StaleTempAge    time.Duration
+	AuditOutput     string
+	DeadLetter      string
+	OnSuccess       []*service.OwnedProcessor
+	Verify          bool
 }
 
 func fileProcessorConfigFromParsed(pConf *service.ParsedConfig) (conf fileProcessorConfig, err error) {
 	if conf.Operation, err = pConf.FieldString(fileProcessorFieldOperation); err != nil {
 		return
 	}
-	if conf.Path, err = pConf.FieldInterpolatedString(fileProcessorFieldPath); err != nil {
+	if pConf.Contains(fileProcessorFieldPaths) {
+		if conf.Operation != fileProcessorOpDelete && conf.Operation != fileProcessorOpWrite {
+			err = errors.New("paths is only supported by the " + fileProcessorOpDelete + " and " + fileProcessorOpWrite + " operations")
+			return
+		}
+		if conf.Paths, err = pConf.FieldBloblang(fileProcessorFieldPaths); err != nil {
+			return
+		}
+	} else if conf.Path, err = pConf.FieldInterpolatedString(fileProcessorFieldPath); err != nil {
 		return
 	}
-	if conf.DestinationPath, err = pConf.FieldInterpolatedString(fileProcessorFieldDest); err != nil {
-		// DestinationPath is optional for operations other than move and rename
-		if conf.Operation != fileProcessorOpMove && conf.Operation != fileProcessorOpRename {
-			conf.DestinationPath = nil
-			err = nil
-		}
+	if conf.Limit, err = pConf.FieldInt(fileProcessorFieldLimit); err != nil {
+		return
 	}
-
-	return
-}
-
-//------------------------------------------------------------------------------
-
-type fileProcessor struct {
-	log     *service.Logger
-	nm      *service.Resources
-	scanner *service.OwnedScannerCreator
-	conf    fileProcessorConfig
-}
-
-func fileProcessorFromParsed(conf *service.ParsedConfig, nm *service.Resources) (*fileProcessor, error) {
-	pConf, err := fileProcessorConfigFromParsed(conf)
-	if err != nil {
-		return nil, err
+	if conf.Collapse, err = pConf.FieldBool(fileProcessorFieldCollapse); err != nil {
+		return
 	}
-
-	// Scanner is required for read operations
-	var scan *service.OwnedScannerCreator
-	if pConf.Operation == fileProcessorOpRead {
-		scan, err = conf.FieldScanner(fileProcessorFieldScanner)
-		if err != nil {
-			return nil, err
-		}
+	if conf.ByteOffsets, err = pConf.FieldBool(fileProcessorFieldOffsets); err != nil {
+		return
 	}
-
-	return &fileProcessor{
-		log:     nm.Logger(),
-		nm:      nm,
-		scanner: scan,
-		conf:    pConf,
-	}, nil
-}
-
-func (p *fileProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
-	switch p.conf.Operation {
-	case fileProcessorOpRead:
-		return p.processRead(ctx, msg)
-	case fileProcessorOpWrite:
-		return p.processWrite(msg)
-	case fileProcessorOpDelete:
-		return p.processDelete(msg)
-	case fileProcessorOpMove:
-		return p.processMove(ctx, msg)
-	case fileProcessorOpRename:
-		return p.processRename(msg)
-	case fileProcessorOpStat:
-		return p.processStat(msg)
-	default:
-		return nil, fmt.Errorf("unsupported operation: %s", p.conf.Operation)
+	if conf.ByteOffsets && pConf.Contains(fileProcessorFieldScanner) {
+		err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldOffsets, fileProcessorFieldScanner)
+		return
 	}
-}
-
-func (p *fileProcessor) processRead(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
-	path, err := p.conf.Path.TryString(msg)
-	if err != nil {
-		return nil, fmt.Errorf("path interpolation error: %w", err)
+	if conf.PartMetadata, err = pConf.FieldBool(fileProcessorFieldPartMeta); err != nil {
+		return
 	}
-	path = filepath.Clean(path)
-
-	file, err := p.nm.FS().Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file '%s': %w", path, err)
+	if conf.PartMetadata && conf.Collapse {
+		err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldPartMeta, fileProcessorFieldCollapse)
+		return
 	}
-	defer file.Close()
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+	if conf.OnEmpty, err = pConf.FieldString(fileProcessorFieldOnEmpty); err != nil {
+		return
 	}
-
-	details := service.NewScannerSourceDetails()
-	details.SetName(path)
-
-	scanner, err := p.scanner.Create(file, func(ctx context.Context, err error) error {
-		return nil
-	}, details)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create scanner for file '%s': %w", path, err)
+	if pConf.Contains(fileProcessorFieldScanWait) {
+		if conf.ScannerTimeout, err = pConf.FieldDuration(fileProcessorFieldScanWait); err != nil {
+			return
+		}
 	}
-	defer scanner.Close(ctx)
-
-	var allMessages service.MessageBatch
-
+	if conf.OutputCodec, err = pConf.FieldString(fileProcessorFieldOutCodec); err != nil {
+		return
+	}
+	if pConf.Contains(fileProcessorFieldFraming) {
+		if conf.Framing, err = pConf.FieldString(fileProcessorFieldFraming); err != nil {
+			return
+		}
+		if !slices.Contains(fileProcessorContentOps, conf.Operation) {
+			err = fileProcessorContentOpsErr(fileProcessorFieldFraming)
+			return
+		}
+	}
+	if pConf.Contains(fileProcessorFieldSplitRe) {
+		var splitRegexStr string
+		if splitRegexStr, err = pConf.FieldString(fileProcessorFieldSplitRe); err != nil {
+			return
+		}
+		if conf.SplitRegex, err = regexp.Compile(splitRegexStr); err != nil {
+			err = fmt.Errorf("failed to compile %v: %w", fileProcessorFieldSplitRe, err)
+			return
+		}
+		if conf.SplitRegex.MatchString("") {
+			err = fmt.Errorf("%v must not match an empty string", fileProcessorFieldSplitRe)
+			return
+		}
+	}
+	if pConf.Contains(fileProcessorFieldChunkSize) {
+		var chunkSizeStr string
+		if chunkSizeStr, err = pConf.FieldString(fileProcessorFieldChunkSize); err != nil {
+			return
+		}
+		var chunkSize uint64
+		if chunkSize, err = humanize.ParseBytes(chunkSizeStr); err != nil {
+			err = fmt.Errorf("failed to parse %v: %w", fileProcessorFieldChunkSize, err)
+			return
+		}
+		if chunkSize == 0 || chunkSize > math.MaxInt32 {
+			err = fmt.Errorf("%v must be between 1 byte and 2GiB", fileProcessorFieldChunkSize)
+			return
+		}
+		conf.ChunkSize = int(chunkSize)
+	}
+	if pConf.Contains(fileProcessorFieldReadBuf) {
+		var bufSizeStr string
+		if bufSizeStr, err = pConf.FieldString(fileProcessorFieldReadBuf); err != nil {
+			return
+		}
+		var bufSize uint64
+		if bufSize, err = humanize.ParseBytes(bufSizeStr); err != nil {
+			err = fmt.Errorf("failed to parse %v: %w", fileProcessorFieldReadBuf, err)
+			return
+		}
+		if bufSize == 0 || bufSize > math.MaxInt32 {
+			err = fmt.Errorf("%v must be between 1 byte and 2GiB", fileProcessorFieldReadBuf)
+			return
+		}
+		conf.ReadBufferSize = int(bufSize)
+	}
+	if conf.FollowSymlinks, err = pConf.FieldBool(fileProcessorFieldSymlinks); err != nil {
+		return
+	}
+	if pConf.Contains(fileProcessorFieldOffCache) {
+		if conf.OffsetCache, err = pConf.FieldString(fileProcessorFieldOffCache); err != nil {
+			return
+		}
+		if conf.Limit > 0 {
+			err = errors.New("offset_cache cannot be combined with limit")
+			return
+		}
+	}
+	if pConf.Contains(fileProcessorFieldOffKey) {
+		if conf.OffsetKey, err = pConf.FieldInterpolatedString(fileProcessorFieldOffKey); err != nil {
+			return
+		}
+	}
+	if conf.CommitOffsets, err = pConf.FieldBool(fileProcessorFieldOffCommit); err != nil {
+		return
+	}
+	if conf.CommitOffsets && conf.OffsetCache == "" {
+		err = fmt.Errorf("%v is required when %v is enabled", fileProcessorFieldOffCache, fileProcessorFieldOffCommit)
+		return
+	}
+	if conf.Decode, err = pConf.FieldString(fileProcessorFieldDecode); err != nil {
+		return
+	}
+	if conf.ByteOffsets && conf.Decode != fileProcessorEncNone {
+		err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldOffsets, fileProcessorFieldDecode)
+		return
+	}
+	if conf.Encode, err = pConf.FieldString(fileProcessorFieldEncode); err != nil {
+		return
+	}
+	if conf.Encode != fileProcessorEncNone && !slices.Contains(fileProcessorContentOps, conf.Operation) {
+		err = fileProcessorContentOpsErr(fileProcessorFieldEncode)
+		return
+	}
+	if conf.EnsureNewline, err = pConf.FieldBool(fileProcessorFieldNewline); err != nil {
+		return
+	}
+	if conf.EnsureNewline && !slices.Contains(fileProcessorContentOps, conf.Operation) {
+		err = fileProcessorContentOpsErr(fileProcessorFieldNewline)
+		return
+	}
+	if conf.BOM, err = pConf.FieldString(fileProcessorFieldBOM); err != nil {
+		return
+	}
+	if conf.BOM == fileProcessorBOMStrip && !slices.Contains(fileProcessorReadOps, conf.Operation) {
+		err = fmt.Errorf("bom %v is only supported by the %v operations", fileProcessorBOMStrip, strings.Join(fileProcessorReadOps, " and "))
+		return
+	}
+	if conf.BOM == fileProcessorBOMAdd && conf.Operation != fileProcessorOpWrite {
+		err = fmt.Errorf("bom %v is only supported by the %v operation", fileProcessorBOMAdd, fileProcessorOpWrite)
+		return
+	}
+	if conf.Framing != "" && (conf.EnsureNewline || conf.BOM == fileProcessorBOMAdd) {
+		err = fmt.Errorf("%v cannot be combined with %v or %v %v", fileProcessorFieldFraming, fileProcessorFieldNewline, fileProcessorFieldBOM, fileProcessorBOMAdd)
+		return
+	}
+	if conf.ErrorMode, err = pConf.FieldString(fileProcessorFieldErrMode); err != nil {
+		return
+	}
+	if conf.AllOrNothing, err = pConf.FieldBool(fileProcessorFieldAllOrNone); err != nil {
+		return
+	}
+	if conf.Workers, err = pConf.FieldInt(fileProcessorFieldWorkers); err != nil {
+		return
+	}
+	if conf.Workers < 1 {
+		err = fmt.Errorf("%v must be at least 1", fileProcessorFieldWorkers)
+		return
+	}
+	if conf.PreserveTree, err = pConf.FieldBool(fileProcessorFieldTree); err != nil {
+		return
+	}
+	if conf.PreserveTree && conf.Operation != fileProcessorOpMove && conf.Operation != fileProcessorOpRename {
+		err = errors.New("preserve_tree is only supported by the " + fileProcessorOpMove + " and " + fileProcessorOpRename + " operations")
+		return
+	}
+	if pConf.Contains(fileProcessorFieldBase) {
+		if conf.SourceBase, err = pConf.FieldInterpolatedString(fileProcessorFieldBase); err != nil {
+			return
+		}
+	} else if conf.PreserveTree {
+		err = errors.New("source_base is required when preserve_tree is enabled")
+		return
+	}
+	if conf.DestinationPath, err = pConf.FieldInterpolatedString(fileProcessorFieldDest); err != nil {
+		// DestinationPath is optional for operations other than those listed
+		// in fileProcessorDestOps
+		if !fileProcessorOpRequiresDest(conf.Operation) {
+			conf.DestinationPath = nil
+			err = nil
+		}
+	}
+	if err != nil {
+		return
+	}
+	if conf.Pattern, err = pConf.FieldInterpolatedString(fileProcessorFieldPattern); err != nil {
+		return
+	}
+	if pConf.Contains(fileProcessorFieldPredicate) {
+		if conf.Predicate, err = pConf.FieldBloblang(fileProcessorFieldPredicate); err != nil {
+			return
+		}
+	} else if conf.Operation == fileProcessorOpFilter {
+		err = errors.New("predicate is required for " + fileProcessorOpFilter + " operation")
+		return
+	}
+	if pConf.Contains(fileProcessorFieldExpected) {
+		if conf.Expected, err = pConf.FieldInterpolatedString(fileProcessorFieldExpected); err != nil {
+			return
+		}
+	} else if conf.Operation == fileProcessorOpVerify {
+		err = errors.New("expected_checksum is required for " + fileProcessorOpVerify + " operation")
+		return
+	}
+	if conf.Algorithm, err = pConf.FieldString(fileProcessorFieldAlgorithm); err != nil {
+		return
+	}
+	if conf.FailOnMismatch, err = pConf.FieldBool(fileProcessorFieldMismatch); err != nil {
+		return
+	}
+	if conf.FailOnACLError, err = pConf.FieldBool(fileProcessorFieldFailOnErr); err != nil {
+		return
+	}
+	if conf.Emit, err = pConf.FieldString(fileProcessorFieldEmit); err != nil {
+		return
+	}
+	if conf.Paths != nil && conf.Operation == fileProcessorOpWrite && conf.Emit != fileProcessorEmitInput {
+		err = errors.New("emit is not supported when writing to a list of paths")
+		return
+	}
+	if conf.OnDeleteFailure, err = pConf.FieldString(fileProcessorFieldOnDelFail); err != nil {
+		return
+	}
+	if conf.SamePath, err = pConf.FieldString(fileProcessorFieldSamePath); err != nil {
+		return
+	}
+	if conf.OnConflict, err = pConf.FieldString(fileProcessorFieldConflict); err != nil {
+		return
+	}
+	if pConf.Contains(fileProcessorFieldStaleAge) {
+		if conf.StaleTempAge, err = pConf.FieldDuration(fileProcessorFieldStaleAge); err != nil {
+			return
+		}
+	}
+	if conf.Validate, err = pConf.FieldString(fileProcessorFieldValidate); err != nil {
+		return
+	}
+	if pConf.Contains(fileProcessorFieldValCheck) {
+		if conf.ValidateCheck, err = pConf.FieldBloblang(fileProcessorFieldValCheck); err != nil {
+			return
+		}
+	}
+	if !slices.Contains(fileProcessorContentOps, conf.Operation) {
+		if conf.Validate != fileProcessorValidateNone {
+			err = fileProcessorContentOpsErr(fileProcessorFieldValidate)
+			return
+		}
+		if conf.ValidateCheck != nil {
+			err = fileProcessorContentOpsErr(fileProcessorFieldValCheck)
+			return
+		}
+	}
+	if conf.SkipUnchanged, err = pConf.FieldBool(fileProcessorFieldSkipSame); err != nil {
+		return
+	}
+	if conf.ContentAddress, err = pConf.FieldBool(fileProcessorFieldContentID); err != nil {
+		return
+	}
+	if conf.ContentAddress {
+		if conf.Operation != fileProcessorOpWrite {
+			err = fmt.Errorf("%v is only supported by the write operation", fileProcessorFieldContentID)
+			return
+		}
+		if conf.Paths != nil {
+			err = fmt.Errorf("%v is not supported when writing to a list of paths", fileProcessorFieldContentID)
+			return
+		}
+		if conf.SkipUnchanged {
+			err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldContentID, fileProcessorFieldSkipSame)
+			return
+		}
+	}
+	if pConf.Contains(fileProcessorFieldFallback) {
+		if conf.Operation != fileProcessorOpWrite {
+			err = fmt.Errorf("%v is only supported by the write operation", fileProcessorFieldFallback)
+			return
+		}
+		if conf.Paths != nil {
+			err = fmt.Errorf("%v is not supported when writing to a list of paths", fileProcessorFieldFallback)
+			return
+		}
+		if conf.ContentAddress {
+			err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldFallback, fileProcessorFieldContentID)
+			return
+		}
+		if conf.FallbackPath, err = pConf.FieldInterpolatedString(fileProcessorFieldFallback); err != nil {
+			return
+		}
+		if err = validateStaticPath(fileProcessorFieldFallback, conf.FallbackPath); err != nil {
+			return
+		}
+	}
+	if conf.Socket, err = pConf.FieldBool(fileProcessorFieldSocket); err != nil {
+		return
+	}
+	if conf.Socket {
+		if conf.Operation != fileProcessorOpWrite {
+			err = fmt.Errorf("%v is only supported by the write operation", fileProcessorFieldSocket)
+			return
+		}
+		if conf.Paths != nil {
+			err = fmt.Errorf("%v is not supported when writing to a list of paths", fileProcessorFieldSocket)
+			return
+		}
+		if conf.SkipUnchanged {
+			err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldSocket, fileProcessorFieldSkipSame)
+			return
+		}
+	}
+	if pConf.Contains(fileProcessorFieldStageDir) {
+		if conf.Operation != fileProcessorOpWrite {
+			err = fmt.Errorf("%v is only supported by the write operation", fileProcessorFieldStageDir)
+			return
+		}
+		if conf.Paths != nil {
+			err = fmt.Errorf("%v is not supported when writing to a list of paths", fileProcessorFieldStageDir)
+			return
+		}
+		if conf.Socket {
+			err = fmt.Errorf("%v cannot be combined with %v", fileProcessorFieldStageDir, fileProcessorFieldSocket)
+			return
+		}
+		if conf.StageDir, err = pConf.FieldString(fileProcessorFieldStageDir); err != nil {
+			return
+		}
+		if strings.TrimSpace(conf.StageDir) == "" {
+			err = fmt.Errorf("%v must not be empty", fileProcessorFieldStageDir)
+			return
+		}
+		conf.StageDir = filepath.Clean(conf.StageDir)
+	}
+	if conf.SyncPolicy, err = pConf.FieldString(fileProcessorFieldSyncPol); err != nil {
+		return
+	}
+	if conf.SyncPolicy == fileProcessorSyncInterval {
+		if conf.SyncInterval, err = pConf.FieldDuration(fileProcessorFieldSyncEvery); err != nil {
+			return
+		}
+		if conf.SyncInterval <= 0 {
+			err = fmt.Errorf("%v must be greater than zero", fileProcessorFieldSyncEvery)
+			return
+		}
+	}
+	if conf.FileMode, err = fileModeFromParsed(pConf, fileProcessorFieldFileMode); err != nil {
+		return
+	}
+	if conf.DirMode, err = fileModeFromParsed(pConf, fileProcessorFieldDirMode); err != nil {
+		return
+	}
+	if conf.DirectIO, err = pConf.FieldBool(fileProcessorFieldDirectIO); err != nil {
+		return
+	}
+	if conf.CheckSpace, err = pConf.FieldBool(fileProcessorFieldChkSpace); err != nil {
+		return
+	}
+	if conf.Paths != nil && conf.Operation == fileProcessorOpWrite && (conf.SkipUnchanged || conf.CheckSpace) {
+		err = errors.New("skip_unchanged and check_space are not supported when writing to a list of paths")
+		return
+	}
+	var minSpaceStr string
+	if minSpaceStr, err = pConf.FieldString(fileProcessorFieldMinSpace); err != nil {
+		return
+	}
+	if conf.MinFreeSpace, err = humanize.ParseBytes(minSpaceStr); err != nil {
+		err = fmt.Errorf("failed to parse %v: %w", fileProcessorFieldMinSpace, err)
+		return
+	}
+	if pConf.Contains(fileProcessorFieldThreshold) {
+		var thresholdStr string
+		if thresholdStr, err = pConf.FieldString(fileProcessorFieldThreshold); err != nil {
+			return
+		}
+		var threshold uint64
+		if threshold, err = humanize.ParseBytes(thresholdStr); err != nil {
+			err = fmt.Errorf("failed to parse %v: %w", fileProcessorFieldThreshold, err)
+			return
+		}
+		if threshold > math.MaxInt64 {
+			err = fmt.Errorf("%v is too large", fileProcessorFieldThreshold)
+			return
+		}
+		conf.SizeThreshold = int64(threshold)
+	} else if conf.Operation == fileProcessorOpWatch {
+		err = errors.New("size_threshold is required for " + fileProcessorOpWatch + " operation")
+		return
+	}
+	if conf.PollInterval, err = pConf.FieldDuration(fileProcessorFieldInterval); err != nil {
+		return
+	}
+	if conf.PollInterval <= 0 {
+		err = fmt.Errorf("%v must be greater than zero", fileProcessorFieldInterval)
+		return
+	}
+	var step int
+	if step, err = pConf.FieldInt(fileProcessorFieldStep); err != nil {
+		return
+	}
+	conf.Step = int64(step)
+	if conf.KeepVersions, err = pConf.FieldInt(fileProcessorFieldVersions); err != nil {
+		return
+	}
+	if conf.KeepVersions < 0 {
+		err = fmt.Errorf("%v must not be negative", fileProcessorFieldVersions)
+		return
+	}
+	if conf.MaxDiffLines, err = pConf.FieldInt(fileProcessorFieldDiffLines); err != nil {
+		return
+	}
+	if conf.MaxDiffLines < 0 {
+		err = fmt.Errorf("%v must not be negative", fileProcessorFieldDiffLines)
+		return
+	}
+	if conf.Recursive, err = pConf.FieldBool(fileProcessorFieldRecursive); err != nil {
+		return
+	}
+	if conf.Recursive && conf.Operation != fileProcessorOpList {
+		err = fmt.Errorf("%v is only supported by the list operation", fileProcessorFieldRecursive)
+		return
+	}
+	if conf.RenameRetries, err = pConf.FieldInt(fileProcessorFieldRetries); err != nil {
+		return
+	}
+	if conf.RenameDelay, err = pConf.FieldDuration(fileProcessorFieldRetryWait); err != nil {
+		return
+	}
+	if pConf.Contains(fileProcessorFieldDeadEnd) {
+		if conf.DeadLetter, err = pConf.FieldString(fileProcessorFieldDeadEnd); err != nil {
+			return
+		}
+	}
+	if pConf.Contains(fileProcessorFieldAudit) {
+		if conf.AuditOutput, err = pConf.FieldString(fileProcessorFieldAudit); err != nil {
+			return
+		}
+	}
+	if conf.OnSuccess, err = pConf.FieldProcessorList(fileProcessorFieldOnSuccess); err != nil {
+		return
+	}
+	if conf.Verify, err = pConf.FieldBool(fileProcessorFieldVerify); err != nil {
+		return
+	}
+	if err = validateStaticPath(fileProcessorFieldPath, conf.Path); err != nil {
+		return
+	}
+	if fileProcessorOpRequiresDest(conf.Operation) {
+		if err = validateStaticPath(fileProcessorFieldDest, conf.DestinationPath); err != nil {
+			return
+		}
+	}
+	if err = validateStaticPath(fileProcessorFieldBase, conf.SourceBase); err != nil {
+		return
+	}
+
+	return
+}
+
+// validateStaticPath returns an error when path is static and could never
+// refer to a file, such that the mistake is reported at config time rather
+// than for each message. Paths containing interpolation functions are only
+// resolved per message and are not checked.
+func validateStaticPath(field string, path *service.InterpolatedString) error {
+	if path == nil {
+		return nil
+	}
+	str, isStatic := path.Static()
+	if !isStatic {
+		return nil
+	}
+	if strings.TrimSpace(str) == "" {
+		return fmt.Errorf("%v must not be empty", field)
+	}
+	if strings.ContainsRune(str, 0) {
+		return fmt.Errorf("%v '%s' must not contain a null byte", field, strings.ReplaceAll(str, "\x00", `\x00`))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+type fileProcessor struct {
+	log       *service.Logger
+	nm        *service.Resources
+	scanner   *service.OwnedScannerCreator
+	codecFunc bufio.SplitFunc
+	conf      fileProcessorConfig
+
+	// deleteRetryBackoff is the base delay between attempts to delete the
+	// source file of a move, multiplied by the attempt number.
+	deleteRetryBackoff time.Duration
+
+	// rename moves a file from oldpath to newpath.
+	rename func(oldpath, newpath string) error
+
+	// counterMut serialises counter operations.
+	counterMut sync.Mutex
+
+	// syncer batches the flushing of written files when sync_policy is
+	// interval.
+	syncer *fileSyncer
+}
+
+func fileProcessorFromParsed(conf *service.ParsedConfig, nm *service.Resources) (*fileProcessor, error) {
+	pConf, err := fileProcessorConfigFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	if pConf.AuditOutput != "" && !nm.HasOutput(pConf.AuditOutput) {
+		return nil, fmt.Errorf("output resource '%v' was not found", pConf.AuditOutput)
+	}
+	if pConf.DeadLetter != "" && !nm.HasOutput(pConf.DeadLetter) {
+		return nil, fmt.Errorf("output resource '%v' was not found", pConf.DeadLetter)
+	}
+	if pConf.OffsetCache != "" && !nm.HasCache(pConf.OffsetCache) {
+		return nil, fmt.Errorf("cache resource '%v' was not found", pConf.OffsetCache)
+	}
+	if pConf.Operation == fileProcessorOpSwap && !ifs.IsOS(interop.UnwrapManagement(nm).FS()) {
+		return nil, fmt.Errorf("the %v operation is not supported by custom filesystems", fileProcessorOpSwap)
+	}
+
+	// Either a scanner, a codec, a chunk size or a split_regex is required for
+	// read operations. The split functions of split_regex hold the state of a
+	// single read and so are instead created for each read by readRecords
+	var scan *service.OwnedScannerCreator
+	var codecFunc bufio.SplitFunc
+	if slices.Contains(fileProcessorReadOps, pConf.Operation) && pConf.ChunkSize == 0 && pConf.SplitRegex == nil {
+		if conf.Contains(fileProcessorFieldCodec) {
+			codecName, err := conf.FieldString(fileProcessorFieldCodec)
+			if err != nil {
+				return nil, err
+			}
+			if codecFunc, err = fileProcessorCodecSplitFunc(codecName); err != nil {
+				return nil, err
+			}
+		} else if scan, err = conf.FieldScanner(fileProcessorFieldScanner); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &fileProcessor{
+		log:       nm.Logger(),
+		nm:        nm,
+		scanner:   scan,
+		codecFunc: codecFunc,
+		conf:      pConf,
+
+		deleteRetryBackoff: time.Second,
+		rename:             nm.FS().Rename,
+	}
+	if pConf.Verify {
+		if err := p.verifyPermissions(); err != nil {
+			return nil, err
+		}
+	}
+	if pConf.SyncPolicy == fileProcessorSyncInterval {
+		p.syncer = newFileSyncer(pConf.SyncInterval, p.syncFiles)
+	}
+	if pConf.StaleTempAge > 0 {
+		p.sweepStaleTempFiles()
+	}
+	if pConf.CheckSpace && !spaceCheckSupported {
+		p.log.Warn("Checking available disk space is not supported on this platform, writes will not be checked")
+	}
+	if pConf.DirectIO && directIOFlag == 0 {
+		p.log.Warn("Direct IO is not supported on this platform, writes will be buffered")
+	}
+	return p, nil
+}
+
+// verifyPermissions checks that the operation can access its statically
+// configured paths, dynamic paths are skipped as they can only be resolved
+// against a message.
+func (p *fileProcessor) verifyPermissions() error {
+	if p.conf.Path == nil {
+		return nil
+	}
+	if path, isStatic := p.conf.Path.Static(); isStatic {
+		path = filepath.Clean(path)
+
+		switch p.conf.Operation {
+		case fileProcessorOpRead, fileProcessorOpFilter, fileProcessorOpMove, fileProcessorOpCopy, fileProcessorOpHash, fileProcessorOpList, fileProcessorOpVerify, fileProcessorOpTree, fileProcessorOpPatch, fileProcessorOpReflow, fileProcessorOpRestore, fileProcessorOpSwap, fileProcessorOpDelta, fileProcessorOpGetACL:
+			if err := p.verifyReadable(path); err != nil {
+				return err
+			}
+		}
+		switch p.conf.Operation {
+		case fileProcessorOpWrite, fileProcessorOpDelete, fileProcessorOpMove, fileProcessorOpRename, fileProcessorOpPatch, fileProcessorOpCounter, fileProcessorOpSwap, fileProcessorOpPublish, fileProcessorOpAppend:
+			if err := p.verifyWritableDir(filepath.Dir(path)); err != nil {
+				return err
+			}
+		case fileProcessorOpMktemp:
+			if err := p.verifyWritableDir(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.conf.DestinationPath != nil {
+		if destPath, isStatic := p.conf.DestinationPath.Static(); isStatic {
+			if err := p.verifyWritableDir(filepath.Dir(filepath.Clean(destPath))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// verifyReadable checks that path can be opened for reading if it exists. A
+// file that doesn't exist yet is not an error as it may be created later.
+func (p *fileProcessor) verifyReadable(path string) error {
+	file, err := p.nm.FS().Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("permission check failed, cannot read '%s': %w", path, err)
+	}
+	return file.Close()
+}
+
+// sweepStaleTempFiles removes temporary files left behind by interrupted
+// writes to the static target path of the operation, if there is one, and
+// within the stage directory.
+func (p *fileProcessor) sweepStaleTempFiles() {
+	if p.conf.StageDir != "" {
+		// Every file staged is named after the path being written, which may
+		// be dynamic, so any temporary file within the directory is removed
+		p.sweepStaleTempFilesIn(p.conf.StageDir, func(name string) bool {
+			i := strings.LastIndex(name, ".tmp_")
+			return i > 0 && tempFileSuffixRegex.MatchString(name[i+len(".tmp_"):])
+		})
+	}
+
+	var target *service.InterpolatedString
+	switch p.conf.Operation {
+	case fileProcessorOpWrite:
+		target = p.conf.Path
+	case fileProcessorOpMove, fileProcessorOpCopy, fileProcessorOpFilter, fileProcessorOpReflow:
+		if !p.conf.PreserveTree {
+			target = p.conf.DestinationPath
+		}
+	}
+	if target == nil {
+		return
+	}
+	path, isStatic := target.Static()
+	if !isStatic {
+		return
+	}
+	path = filepath.Clean(path)
+
+	prefix := filepath.Base(path) + ".tmp_"
+	p.sweepStaleTempFilesIn(filepath.Dir(path), func(name string) bool {
+		return strings.HasPrefix(name, prefix) && tempFileSuffixRegex.MatchString(name[len(prefix):])
+	})
+}
+
+// sweepStaleTempFilesIn removes the regular files within dir that are older
+// than stale_temp_file_age and whose names are matched by isTempFile.
+func (p *fileProcessor) sweepStaleTempFilesIn(dir string, isTempFile func(name string) bool) {
+	f, err := p.nm.FS().Open(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			p.log.Warnf("Failed to open directory '%s' to remove stale temporary files: %v", dir, err)
+		}
+		return
+	}
+	dirFile, ok := f.(fs.ReadDirFile)
+	if !ok {
+		f.Close()
+		return
+	}
+	entries, err := dirFile.ReadDir(-1)
+	f.Close()
+	if err != nil {
+		p.log.Warnf("Failed to read directory '%s' to remove stale temporary files: %v", dir, err)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !isTempFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < p.conf.StaleTempAge {
+			continue
+		}
+		tempPath := filepath.Join(dir, name)
+		if err := p.nm.FS().Remove(tempPath); err != nil {
+			p.log.Warnf("Failed to remove stale temporary file '%s': %v", tempPath, err)
+			continue
+		}
+		p.log.Infof("Removed stale temporary file '%s'", tempPath)
+	}
+}
+
+// verifyWritableDir checks that files can be created within dir, or within
+// its closest existing ancestor when dir is yet to be created.
+func (p *fileProcessor) verifyWritableDir(dir string) error {
+	for {
+		info, err := p.nm.FS().Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("permission check failed, '%s' is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("permission check failed, cannot access '%s': %w", dir, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("permission check failed, cannot access '%s': %w", dir, err)
+		}
+		dir = parent
+	}
+
+	probePath, err := generateTempFileName(filepath.Join(dir, ".bento_permission_check"))
+	if err != nil {
+		return err
+	}
+	probe, err := p.nm.FS().OpenFile(probePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fs.FileMode(0o600))
+	if err != nil {
+		return fmt.Errorf("permission check failed, cannot write to directory '%s': %w", dir, err)
+	}
+	_ = probe.Close()
+	if err := p.nm.FS().Remove(probePath); err != nil {
+		return fmt.Errorf("permission check failed, cannot remove files from directory '%s': %w", dir, err)
+	}
+	return nil
+}
+
+func (p *fileProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	start := time.Now()
+	batch, err := p.process(ctx, msg)
+	duration := time.Since(start).Milliseconds()
+	for _, m := range batch {
+		m.MetaSetMut("file_operation_duration_ms", duration)
+	}
+	if p.conf.AuditOutput != "" {
+		p.sendAuditRecord(ctx, msg, batch, err)
+	}
+	if err != nil && p.conf.DeadLetter != "" && !errors.Is(err, component.ErrTimeout) {
+		if dlErr := p.sendDeadLetter(ctx, msg, err); dlErr != nil {
+			p.log.Errorf("Failed to route message to dead letter output '%s': %v", p.conf.DeadLetter, dlErr)
+			return nil, err
+		}
+		return nil, nil
+	}
+	if err != nil || len(p.conf.OnSuccess) == 0 {
+		return batch, err
+	}
+
+	resBatches, err := service.ExecuteProcessors(ctx, p.conf.OnSuccess, batch)
+	if err != nil {
+		return nil, err
+	}
+	var results service.MessageBatch
+	for _, b := range resBatches {
+		results = append(results, b...)
+	}
+	return results, nil
+}
+
+// sendDeadLetter writes a copy of msg annotated with opErr to the dead letter
+// output.
+func (p *fileProcessor) sendDeadLetter(ctx context.Context, msg *service.Message, opErr error) error {
+	dlMsg := msg.Copy()
+	dlMsg.MetaSetMut("file_error", opErr.Error())
+
+	var writeErr error
+	if err := p.nm.AccessOutput(ctx, p.conf.DeadLetter, func(o *service.ResourceOutput) {
+		writeErr = o.Write(ctx, dlMsg)
+	}); err != nil {
+		return err
+	}
+	return writeErr
+}
+
+func (p *fileProcessor) process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	switch p.conf.Operation {
+	case fileProcessorOpRead:
+		return p.processRead(ctx, msg)
+	case fileProcessorOpWrite:
+		if p.conf.Paths != nil {
+			return p.processBulkWrite(ctx, msg)
+		}
+		return p.processWrite(ctx, msg)
+	case fileProcessorOpAppend:
+		return p.processAppend(ctx, msg)
+	case fileProcessorOpDelete:
+		if p.conf.Paths != nil {
+			return p.processBulkDelete(ctx, msg)
+		}
+		return p.processDelete(msg)
+	case fileProcessorOpMove:
+		return p.processMove(ctx, msg)
+	case fileProcessorOpCopy:
+		return p.processCopy(ctx, msg)
+	case fileProcessorOpRename:
+		return p.processRename(msg)
+	case fileProcessorOpStat:
+		return p.processStat(msg)
+	case fileProcessorOpMktemp:
+		return p.processMktemp(msg)
+	case fileProcessorOpFilter:
+		return p.processFilter(ctx, msg)
+	case fileProcessorOpHash:
+		return p.processChecksum(msg)
+	case fileProcessorOpList:
+		return p.processList(ctx, msg)
+	case fileProcessorOpVerify:
+		return p.processVerify(msg)
+	case fileProcessorOpTree:
+		return p.processTreeChecksum(ctx, msg)
+	case fileProcessorOpPatch:
+		return p.processPatch(ctx, msg)
+	case fileProcessorOpReflow:
+		return p.processReflow(ctx, msg)
+	case fileProcessorOpRestore:
+		return p.processRestore(msg)
+	case fileProcessorOpWatch:
+		return p.processWatchSize(ctx, msg)
+	case fileProcessorOpCounter:
+		return p.processCounter(ctx, msg)
+	case fileProcessorOpSwap:
+		return p.processSwap(msg)
+	case fileProcessorOpDelta:
+		return p.processDelta(ctx, msg)
+	case fileProcessorOpGetACL:
+		return p.processGetACL(msg)
+	case fileProcessorOpSetACL:
+		return p.processSetACL(msg)
+	case fileProcessorOpPublish:
+		return p.processPublishVersioned(ctx, msg)
+	default:
+		return nil, fmt.Errorf("unsupported operation: %s", p.conf.Operation)
+	}
+}
+
+// sendAuditRecord writes a record of an operation performed on msg to the
+// audit output resource.
+func (p *fileProcessor) sendAuditRecord(ctx context.Context, msg *service.Message, batch service.MessageBatch, opErr error) {
+	record := map[string]any{
+		"operation": p.conf.Operation,
+		"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if p.conf.Path != nil {
+		if path, err := p.conf.Path.TryString(msg); err == nil {
+			record["path"] = filepath.Clean(path)
+		}
+	}
+	if p.conf.DestinationPath != nil {
+		if destPath, err := p.conf.DestinationPath.TryString(msg); err == nil {
+			record["destination_path"] = filepath.Clean(destPath)
+		}
+	}
+
+	if opErr != nil {
+		record["result"] = "error"
+		record["error"] = opErr.Error()
+	} else {
+		record["result"] = "success"
+		switch p.conf.Operation {
+		case fileProcessorOpWrite:
+			// Count the content as it was written to disk, after it has been
+			// encoded and framed
+			if content, err := p.writableContent(msg); err == nil {
+				record["bytes"] = int64(len(content))
+			}
+		case fileProcessorOpMktemp:
+			if content, err := msg.AsBytes(); err == nil {
+				record["bytes"] = int64(len(content))
+			}
+		case fileProcessorOpRead:
+			var total int64
+			for _, m := range batch {
+				if content, err := m.AsBytes(); err == nil {
+					total += int64(len(content))
+				}
+			}
+			record["bytes"] = total
+		}
+	}
+
+	auditMsg := service.NewMessage(nil)
+	auditMsg.SetStructuredMut(record)
+
+	if err := p.nm.AccessOutput(ctx, p.conf.AuditOutput, func(o *service.ResourceOutput) {
+		err := o.Write(ctx, auditMsg)
+		if err != nil {
+			p.log.Errorf("Failed to write audit record to output '%s': %v", p.conf.AuditOutput, err)
+		}
+	}); err != nil {
+		p.log.Errorf("Failed to access audit output '%s': %v", p.conf.AuditOutput, err)
+	}
+}
+
+func (p *fileProcessor) processRead(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	var linkInfo fs.FileInfo
+	if !p.conf.FollowSymlinks {
+		// The check is skipped for filesystems that are unable to describe a
+		// symbolic link itself, as there is nothing to inspect.
+		if linkInfo, err = p.nm.FS().Lstat(path); errors.Is(err, errors.ErrUnsupported) {
+			linkInfo = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+		} else if linkInfo.Mode()&fs.ModeSymlink != 0 {
+			return nil, fmt.Errorf("refusing to read '%s' as it is a symbolic link", path)
+		}
+	}
+
+	file, err := p.nm.FS().Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+	}
+
+	// Guard against the path being swapped for a link after it was checked
+	if linkInfo != nil && !sameFile(linkInfo, fileInfo) {
+		return nil, fmt.Errorf("refusing to read '%s' as it changed while being opened", path)
+	}
+
+	var reader io.Reader = file
+	var offsetKey string
+	var offset int64
+	if p.conf.OffsetCache != "" {
+		if offsetKey, err = p.readOffsetKey(msg, path); err != nil {
+			return nil, err
+		}
+		if offset, err = p.loadReadOffset(ctx, offsetKey); err != nil {
+			return nil, err
+		}
+		if offset > fileInfo.Size() {
+			p.log.Infof("File '%s' is smaller than its stored offset, reading from the beginning", path)
+			offset = 0
+		}
+		seeker, ok := file.(io.Seeker)
+		if !ok {
+			return nil, fmt.Errorf("failed to seek file '%s': file does not support seeking", path)
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek file '%s': %w", path, err)
+		}
+		// Only read up to the size observed now so that the stored offset
+		// matches what was emitted, even when the file is still growing
+		reader = io.LimitReader(file, fileInfo.Size()-offset)
+	}
+	reader = p.bufferReads(reader)
+	readOffset := offset
+	if p.conf.BOM == fileProcessorBOMStrip && offset == 0 {
+		var stripped int64
+		reader, stripped = stripBOM(reader)
+		offset += stripped
+	}
+
+	var allMessages service.MessageBatch
+
+	// Create a copy of the original message for each record
+	if err := p.readRecords(ctx, fileProcessorDecoder(p.conf.Decode, reader), path, func(record []byte, recordOffset int64) error {
+		newMsg := msg.Copy()
+		newMsg.SetBytes(record)
+		addFileMetadata(newMsg, path, fileInfo)
+		if p.conf.OffsetCache != "" {
+			setReadOffsetMetadata(newMsg, offsetKey, readOffset, fileInfo.Size())
+		}
+		if p.conf.ChunkSize > 0 {
+			newMsg.MetaSetMut("file_chunk_offset", offset+recordOffset)
+		}
+		if p.conf.ByteOffsets {
+			newMsg.MetaSetMut("file_byte_offset", offset+recordOffset)
+		}
+
+		allMessages = append(allMessages, newMsg)
+		if p.conf.Limit > 0 && len(allMessages) >= p.conf.Limit {
+			return errReadLimitReached
+		}
+		return nil
+	}); err != nil && !errors.Is(err, errReadLimitReached) {
+		return nil, err
+	}
+
+	if p.conf.OffsetCache != "" && p.conf.CommitOffsets {
+		if err := p.storeReadOffset(ctx, offsetKey, fileInfo.Size()); err != nil {
+			return nil, err
+		}
+	}
+
+	// If no messages were created (empty file), handle it according to on_empty
+	if len(allMessages) == 0 {
+		switch p.conf.OnEmpty {
+		case fileProcessorOnEmptySkip:
+			return nil, nil
+		case fileProcessorOnEmptyError:
+			return nil, fmt.Errorf("file '%s' yielded no records", path)
+		}
+		newMsg := msg.Copy()
+		addFileMetadata(newMsg, path, fileInfo)
+		if p.conf.OffsetCache != "" {
+			setReadOffsetMetadata(newMsg, offsetKey, readOffset, fileInfo.Size())
+		}
+		return service.MessageBatch{newMsg}, nil
+	}
+
+	if p.conf.Collapse {
+		records := make([][]byte, 0, len(allMessages))
+		for _, m := range allMessages {
+			record, err := m.AsBytes()
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+
+		newMsg := msg.Copy()
+		newMsg.SetBytes(bytes.Join(records, []byte("\n")))
+		addFileMetadata(newMsg, path, fileInfo)
+		if p.conf.OffsetCache != "" {
+			setReadOffsetMetadata(newMsg, offsetKey, readOffset, fileInfo.Size())
+		}
+		newMsg.MetaSetMut("file_record_count", int64(len(records)))
+		return service.MessageBatch{newMsg}, nil
+	}
+
+	if p.conf.PartMetadata {
+		for i, m := range allMessages {
+			m.MetaSetMut("file_part_index", int64(i))
+			m.MetaSetMut("file_part_count", int64(len(allMessages)))
+		}
+	}
+
+	return allMessages, nil
+}
+
+// setReadOffsetMetadata sets the metadata fields that describe the offsets of
+// a read tracked with offset_cache, which allow the offset to be stored once
+// the read has been delivered.
+func setReadOffsetMetadata(msg *service.Message, key string, start, end int64) {
+	msg.MetaSetMut("file_offset_key", key)
+	msg.MetaSetMut("file_read_offset", start)
+	msg.MetaSetMut("file_read_end_offset", end)
+}
+
+// readOffsetKey returns the offset_cache key for the file at path.
+func (p *fileProcessor) readOffsetKey(msg *service.Message, path string) (string, error) {
+	if p.conf.OffsetKey == nil {
+		return path, nil
+	}
+	key, err := p.conf.OffsetKey.TryString(msg)
+	if err != nil {
+		return "", fmt.Errorf("offset key interpolation error: %w", err)
+	}
+	return key, nil
+}
+
+// loadReadOffset returns the offset stored under key, or zero if there is
+// none.
+func (p *fileProcessor) loadReadOffset(ctx context.Context, key string) (offset int64, err error) {
+	if cerr := p.nm.AccessCache(ctx, p.conf.OffsetCache, func(c service.Cache) {
+		var value []byte
+		if value, err = c.Get(ctx, key); err != nil {
+			if errors.Is(err, service.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		offset, err = strconv.ParseInt(string(value), 10, 64)
+	}); cerr != nil {
+		return 0, cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get offset '%s' from cache: %w", key, err)
+	}
+	return offset, nil
+}
+
+// storeReadOffset stores offset under key.
+func (p *fileProcessor) storeReadOffset(ctx context.Context, key string, offset int64) (err error) {
+	if cerr := p.nm.AccessCache(ctx, p.conf.OffsetCache, func(c service.Cache) {
+		err = c.Set(ctx, key, []byte(strconv.FormatInt(offset, 10)), nil)
+	}); cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store offset '%s' in cache: %w", key, err)
+	}
+	return nil
+}
+
+// bufferReads wraps r in a buffer of read_buffer_size, when configured.
+func (p *fileProcessor) bufferReads(r io.Reader) io.Reader {
+	if p.conf.ReadBufferSize <= 0 {
+		return r
+	}
+	return bufio.NewReaderSize(r, p.conf.ReadBufferSize)
+}
+
+// readRecords calls fn with each record read from file until EOF is reached,
+// framing records with either the configured scanner, codec or chunk size.
+// Each record is accompanied by the offset in file at which it began, which is
+// -1 for records framed by a scanner as they do not expose it.
+func (p *fileProcessor) readRecords(ctx context.Context, file io.Reader, path string, fn func(record []byte, offset int64) error) error {
+	if p.conf.ChunkSize > 0 {
+		return readChunkRecords(ctx, file, path, p.conf.ChunkSize, fn)
+	}
+	if p.conf.SplitRegex != nil {
+		return readCodecRecords(ctx, file, path, regexpSplitFunc(p.conf.SplitRegex), fn)
+	}
+	if p.codecFunc != nil {
+		return readCodecRecords(ctx, file, path, p.codecFunc, fn)
+	}
+
+	details := service.NewScannerSourceDetails()
+	details.SetName(path)
+
+	scanner, err := p.scanner.Create(io.NopCloser(file), func(ctx context.Context, err error) error {
+		return nil
+	}, details)
+	if err != nil {
+		return fmt.Errorf("failed to create scanner for file '%s': %w", path, err)
+	}
+	abandoned := false
+	defer func() {
+		if !abandoned {
+			scanner.Close(ctx)
+		}
+	}()
+
 	// Process all batches from scanner until EOF
 	for {
-		parts, _, err := scanner.NextBatch(ctx)
+		var parts service.MessageBatch
+		parts, abandoned, err = p.nextScannerBatch(ctx, scanner)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return component.ErrTimeout
+			}
+			if err == io.EOF {
+				// End of file reached
+				return nil
+			}
+			return fmt.Errorf("failed to read from scanner for file '%s': %w", path, err)
+		}
+
+		for _, part := range parts {
+			partBytes, err := part.AsBytes()
+			if err != nil {
+				return fmt.Errorf("failed to get bytes from part: %w", err)
+			}
+			if err := fn(partBytes, -1); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// nextScannerBatch returns the next batch of records from scanner, bounded by
+// the configured scanner timeout. When the timeout elapses before the scanner
+// returns it is abandoned, which is indicated by the returned bool, and it is
+// closed in the background once the pending call returns.
+func (p *fileProcessor) nextScannerBatch(ctx context.Context, scanner *service.OwnedScanner) (service.MessageBatch, bool, error) {
+	if p.conf.ScannerTimeout <= 0 {
+		parts, _, err := scanner.NextBatch(ctx)
+		return parts, false, err
+	}
+
+	batchCtx, cancel := context.WithTimeout(ctx, p.conf.ScannerTimeout)
+
+	type scannerBatch struct {
+		parts service.MessageBatch
+		err   error
+	}
+	resChan := make(chan scannerBatch, 1)
+	go func() {
+		parts, _, err := scanner.NextBatch(batchCtx)
+		resChan <- scannerBatch{parts: parts, err: err}
+	}()
+
+	select {
+	case res := <-resChan:
+		cancel()
+		if res.err != nil && errors.Is(res.err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, false, fmt.Errorf("scanner did not produce a batch within %v", p.conf.ScannerTimeout)
+		}
+		return res.parts, false, res.err
+	case <-batchCtx.Done():
+	}
+
+	go func() {
+		<-resChan
+		cancel()
+		_ = scanner.Close(context.Background())
+	}()
+	if err := ctx.Err(); err != nil {
+		return nil, true, err
+	}
+	return nil, true, fmt.Errorf("scanner did not produce a batch within %v", p.conf.ScannerTimeout)
+}
+
+func readCodecRecords(ctx context.Context, file io.Reader, path string, split bufio.SplitFunc, fn func(record []byte, offset int64) error) error {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxInt)
+
+	// Track the offset of the data at which each token was found, which is
+	// where its record began
+	var pos, recordOffset int64
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = split(data, atEOF)
+		if token != nil {
+			recordOffset = pos
+		}
+		pos += int64(advance)
+		return
+	})
+
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return component.ErrTimeout
+		}
+		record := make([]byte, len(scanner.Bytes()))
+		copy(record, scanner.Bytes())
+		if err := fn(record, recordOffset); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read from codec for file '%s': %w", path, err)
+	}
+	return nil
+}
+
+func readChunkRecords(ctx context.Context, file io.Reader, path string, size int, fn func(record []byte, offset int64) error) error {
+	var pos int64
+	for {
+		if ctx.Err() != nil {
+			return component.ErrTimeout
+		}
+		chunk := make([]byte, size)
+		n, err := io.ReadFull(file, chunk)
+		if n > 0 {
+			if err := fn(chunk[:n], pos); err != nil {
+				return err
+			}
+			pos += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read chunk from file '%s': %w", path, err)
+		}
+	}
+}
+
+// writeCodecRecord writes record to w framed by codec, such that it can be read
+// back with the split function returned by fileProcessorCodecSplitFunc.
+func writeCodecRecord(w io.Writer, codec string, record []byte) error {
+	var prefix, suffix []byte
+	switch codec {
+	case "lines":
+		suffix = []byte("\n")
+	case "length_prefixed_uint16_be":
+		if len(record) > math.MaxUint16 {
+			return fmt.Errorf("record of %d bytes is too large to be length prefixed", len(record))
+		}
+		prefix = binary.BigEndian.AppendUint16(nil, uint16(len(record)))
+	case "length_prefixed_uint32_be":
+		if uint64(len(record)) > math.MaxUint32 {
+			return fmt.Errorf("record of %d bytes is too large to be length prefixed", len(record))
+		}
+		prefix = binary.BigEndian.AppendUint32(nil, uint32(len(record)))
+	case "length_prefixed_uint64_be":
+		prefix = binary.BigEndian.AppendUint64(nil, uint64(len(record)))
+	case "netstring":
+		prefix = []byte(strconv.Itoa(len(record)) + ":")
+		suffix = []byte(",")
+	default:
+		return fmt.Errorf("invalid codec option: %v", codec)
+	}
+	if err := writeFull(w, prefix); err != nil {
+		return err
+	}
+	if err := writeFull(w, record); err != nil {
+		return err
+	}
+	return writeFull(w, suffix)
+}
+
+func fileProcessorCodecSplitFunc(codec string) (bufio.SplitFunc, error) {
+	switch codec {
+	case "lines":
+		return bufio.ScanLines, nil
+	case "length_prefixed_uint16_be", "length_prefixed_uint32_be", "length_prefixed_uint64_be":
+		return strictSplitFunc(lengthPrefixedBESplitFunc(fileProcessorLengthPrefixWidths[codec])), nil
+	case "netstring":
+		return strictSplitFunc(netstringSplitFunc), nil
+	}
+	return nil, fmt.Errorf("invalid codec option: %v", codec)
+}
+
+// lengthPrefixedBESplitFunc returns a split function for records prefixed by
+// their length as a big-endian unsigned integer of width bytes.
+func lengthPrefixedBESplitFunc(width int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF || len(data) < width {
+			return 0, nil, nil
+		}
+		var l uint64
+		switch width {
+		case 2:
+			l = uint64(binary.BigEndian.Uint16(data))
+		case 4:
+			l = uint64(binary.BigEndian.Uint32(data))
+		default:
+			l = binary.BigEndian.Uint64(data)
+		}
+		if l > uint64(maxInt-width) {
+			return 0, nil, errors.New("number of bytes to read exceeds representable range of go int datatype")
+		}
+		if bytesToRead := int(l); len(data)-width >= bytesToRead {
+			return width + bytesToRead, data[width : width+bytesToRead], nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// regexpSplitFunc returns a split function that emits the data between each
+// match of re. A match is only accepted once data follows it, or at EOF, so
+// that delimiters spanning the end of the buffer are not split prematurely.
+//
+// When the length of a match is bounded the search resumes where the previous
+// call left off, less the longest possible match, so that a long record
+// without a delimiter is not searched again each time more of it is read. The
+// returned function holds this position and so must only be used for a single
+// read.
+func regexpSplitFunc(re *regexp.Regexp) bufio.SplitFunc {
+	maxLen := regexpMaxLen(re)
+	var searched int
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		from := 0
+		if maxLen >= 0 {
+			from = min(searched, len(data))
+		}
+		if loc := re.FindIndex(data[from:]); loc != nil {
+			loc[0], loc[1] = loc[0]+from, loc[1]+from
+			if loc[0] == loc[1] {
+				return 0, nil, errors.New("split_regex matched an empty delimiter")
+			}
+			if loc[1] < len(data) || atEOF {
+				searched = 0
+				return loc[1], data[:loc[0]], nil
+			}
+			// The match may yet be extended, or preceded by a longer one
+			searched = max(0, min(loc[0], len(data)-maxLen))
+			return 0, nil, nil
+		}
+		if atEOF && len(data) > 0 {
+			searched = 0
+			return len(data), data, nil
+		}
+		searched = max(0, len(data)-maxLen)
+		return 0, nil, nil
+	}
+}
+
+// regexpMaxLen returns the length in bytes of the longest string that re can
+// match, or -1 when it is unbounded. Expressions containing assertions that
+// depend on the preceding text, such as word boundaries, are also treated as
+// unbounded as they cannot be matched from an arbitrary position.
+func regexpMaxLen(re *regexp.Regexp) int {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return -1
+	}
+	var width func(re *syntax.Regexp) int
+	width = func(re *syntax.Regexp) int {
+		switch re.Op {
+		case syntax.OpEmptyMatch, syntax.OpNoMatch, syntax.OpEndLine, syntax.OpEndText:
+			return 0
+		case syntax.OpLiteral:
+			if re.Flags&syntax.FoldCase != 0 {
+				return len(re.Rune) * utf8.UTFMax
+			}
+			n := 0
+			for _, r := range re.Rune {
+				if l := utf8.RuneLen(r); l > 0 {
+					n += l
+				} else {
+					n += utf8.UTFMax
+				}
+			}
+			return n
+		case syntax.OpCharClass:
+			if len(re.Rune) == 0 {
+				return 0
+			}
+			return utf8.RuneLen(re.Rune[len(re.Rune)-1])
+		case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+			return utf8.UTFMax
+		case syntax.OpCapture, syntax.OpQuest:
+			return width(re.Sub[0])
+		case syntax.OpRepeat:
+			if re.Max < 0 {
+				return -1
+			}
+			if n := width(re.Sub[0]); n >= 0 {
+				return n * re.Max
+			}
+			return -1
+		case syntax.OpConcat, syntax.OpAlternate:
+			total := 0
+			for _, sub := range re.Sub {
+				n := width(sub)
+				if n < 0 {
+					return -1
+				}
+				if re.Op == syntax.OpConcat {
+					total += n
+				} else {
+					total = max(total, n)
+				}
+			}
+			return total
+		}
+		return -1
+	}
+	return width(parsed)
+}
+
+// strictSplitFunc wraps a split function that silently discards any data
+// remaining at EOF such that an incomplete trailing record is reported as an
+// error instead.
+func strictSplitFunc(split bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if !atEOF || len(data) == 0 {
+			return split(data, atEOF)
+		}
+		if advance, token, err = split(data, false); err != nil || advance > 0 {
+			return
+		}
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+}
+
+func (p *fileProcessor) processWrite(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	content, err := p.writableContent(msg)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to write '%s': %w", path, err)
+	}
+
+	var skipped bool
+	if p.conf.SkipUnchanged {
+		if skipped, err = p.fileContentMatches(path, content); err != nil {
+			return nil, err
+		}
+	}
+	if p.conf.ContentAddress {
+		if path, skipped, err = p.contentAddressedPath(path, content); err != nil {
+			return nil, err
+		}
+	}
+
+	var available uint64
+	checkSpace := p.conf.CheckSpace && spaceCheckSupported
+	if checkSpace {
+		if available, err = p.availableSpace(path); err != nil {
+			return nil, err
+		}
+		if !skipped && available < p.conf.MinFreeSpace+uint64(len(content)) {
+			return nil, fmt.Errorf("refusing to write %d bytes to '%s' as only %d bytes are available", len(content), path, available)
+		}
+	}
+
+	var fallbackUsed bool
+	var writeDuration, transferDuration time.Duration
+	if !skipped {
+		if p.conf.StageDir != "" {
+			writeDuration, transferDuration, err = p.writeStaged(ctx, path, content)
+		} else {
+			err = p.writeContent(ctx, path, content)
+		}
+		if err != nil {
+			if p.conf.FallbackPath == nil || !isFallbackWriteError(err) {
+				return nil, err
+			}
+			if path, err = p.writeFallback(ctx, msg, path, content, err); err != nil {
+				return nil, err
+			}
+			fallbackUsed = true
+		}
+	}
+
+	if !p.conf.SkipUnchanged && !p.conf.ContentAddress && p.conf.FallbackPath == nil && p.conf.StageDir == "" && !checkSpace && p.conf.Emit == fileProcessorEmitInput {
+		return service.MessageBatch{msg}, nil
+	}
+
+	newMsg := msg.Copy()
+	if checkSpace {
+		newMsg.MetaSetMut("file_space_available", int64(available))
+	}
+	if p.conf.ContentAddress || p.conf.FallbackPath != nil {
+		newMsg.MetaSetMut("file_path", path)
+	}
+	if p.conf.FallbackPath != nil {
+		newMsg.MetaSetMut("file_fallback_used", fallbackUsed)
+	}
+	if p.conf.StageDir != "" {
+		newMsg.MetaSetMut("file_write_duration_ms", writeDuration.Milliseconds())
+		newMsg.MetaSetMut("file_transfer_duration_ms", transferDuration.Milliseconds())
+	}
+	if p.conf.SkipUnchanged || p.conf.ContentAddress {
+		newMsg.MetaSetMut("file_write_skipped", skipped)
+	}
+	if p.conf.Emit == fileProcessorEmitReceipt {
+		checksum := sha256.Sum256(content)
+
+		newMsg.SetStructuredMut(map[string]any{
+			"path":      path,
+			"bytes":     int64(len(content)),
+			"checksum":  hex.EncodeToString(checksum[:]),
+			"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+// writableContent returns the content of msg as it should be written to a
+// file, after validation, encoding, framing and the addition of a trailing
+// newline or byte order mark.
+func (p *fileProcessor) writableContent(msg *service.Message) ([]byte, error) {
+	content, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.validateContent(msg, content); err != nil {
+		return nil, err
+	}
+	content = fileProcessorEncode(p.conf.Encode, content)
+	if p.conf.Framing != "" {
+		var framed bytes.Buffer
+		if err := writeCodecRecord(&framed, p.conf.Framing, content); err != nil {
+			return nil, err
+		}
+		content = framed.Bytes()
+	}
+	if p.conf.EnsureNewline && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content[:len(content):len(content)], '\n')
+	}
+	if p.conf.BOM == fileProcessorBOMAdd && !bytes.HasPrefix(content, fileProcessorBOMs[0]) {
+		content = append(slices.Clip(fileProcessorBOMs[0]), content...)
+	}
+	return content, nil
+}
+
+func (p *fileProcessor) processBulkWrite(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	paths, err := p.queryPaths(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := p.writableContent(msg)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to write: %w", err)
+	}
+
+	var results, failures map[string]any
+	if p.conf.AllOrNothing {
+		if err := p.writeAllOrNothing(ctx, paths, content); err != nil {
+			return nil, err
+		}
+		results = make(map[string]any, len(paths))
+		for _, path := range paths {
+			results[path] = true
+		}
+		failures = map[string]any{}
+	} else if results, failures, err = p.forEachPath(ctx, paths, func(ctx context.Context, path string) error {
+		return p.writeContent(ctx, path, content)
+	}); err != nil {
+		return nil, err
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_write_results", results)
+	newMsg.MetaSetMut("file_write_errors", failures)
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+// writeAllOrNothing writes content to a temporary file alongside each of paths
+// and only once all of them are written renames them into place, such that a
+// failure to write any of them leaves every path untouched.
+func (p *fileProcessor) writeAllOrNothing(ctx context.Context, paths []string, content []byte) error {
+	tempFiles := make([]string, 0, len(paths))
+	removeTempFiles := func(from int) {
+		for _, tempFile := range tempFiles[from:] {
+			_ = p.nm.FS().Remove(tempFile)
+		}
+	}
+
+	for _, path := range paths {
+		tempFile, err := p.stageTempFile(path, 0, func(w io.Writer) error {
+			return writeFull(w, content)
+		})
+		if err != nil {
+			removeTempFiles(0)
+			return err
+		}
+		tempFiles = append(tempFiles, tempFile)
+	}
+
+	for i, path := range paths {
+		if err := p.renameWithRetry(ctx, tempFiles[i], path); err != nil {
+			removeTempFiles(i)
+			return fmt.Errorf("failed to rename temporary file '%s' to '%s' after %d of %d paths were written: %w", tempFiles[i], path, i, len(paths), err)
+		}
+	}
+	return p.syncWritten(ctx, paths...)
+}
+
+// availableSpace returns the space available on the filesystem that path
+// will be written to, which is queried from its closest existing ancestor when
+// the parent directory is yet to be created.
+func (p *fileProcessor) availableSpace(path string) (uint64, error) {
+	dir := filepath.Dir(path)
+	for {
+		available, err := diskSpaceAvailable(dir)
+		if err == nil {
+			return available, nil
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, fs.ErrNotExist) || parent == dir {
+			return 0, fmt.Errorf("failed to get available space for '%s': %w", dir, err)
+		}
+		dir = parent
+	}
+}
+
+// writeContent atomically writes content to path, bypassing the page cache
+// when direct IO is enabled and supported.
+func (p *fileProcessor) writeContent(ctx context.Context, path string, content []byte) error {
+	if p.conf.Socket {
+		if fileInfo, err := p.nm.FS().Stat(path); err == nil && fileInfo.Mode()&fs.ModeSocket != 0 {
+			return writeSocket(ctx, path, content)
+		}
+	}
+	if p.conf.DirectIO && directIOFlag != 0 {
+		err := p.atomicWriteFileFlags(ctx, path, directIOFlag, func(w io.Writer) error {
+			return writeDirect(w, content)
+		})
+		if err == nil || !isDirectIOUnsupported(err) {
+			return err
+		}
+		p.log.Debugf("Direct IO is not supported for '%s', falling back to a buffered write: %v", path, err)
+	}
+	return p.atomicWriteFile(ctx, path, func(w io.Writer) error {
+		return writeFull(w, content)
+	})
+}
+
+// writeSocket connects to the unix domain socket at path and writes content to
+// it.
+func writeSocket(ctx context.Context, path string, content []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		if ctx.Err() != nil {
+			return component.ErrTimeout
+		}
+		return fmt.Errorf("failed to connect to socket '%s': %w", path, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if err := writeFull(conn, content); err != nil {
+		return fmt.Errorf("failed to write to socket '%s': %w", path, err)
+	}
+	return nil
+}
+
+// writeFallback writes content to the fallback path after writing to path
+// failed with writeErr, and returns the fallback path.
+func (p *fileProcessor) writeFallback(ctx context.Context, msg *service.Message, path string, content []byte, writeErr error) (string, error) {
+	fallback, err := p.conf.FallbackPath.TryString(msg)
+	if err != nil {
+		return "", fmt.Errorf("fallback path interpolation error: %w", err)
+	}
+	fallback = filepath.Clean(fallback)
+
+	p.log.Warnf("Failed to write '%s', writing to fallback path '%s' instead: %v", path, fallback, writeErr)
+	if err := p.writeContent(ctx, fallback, content); err != nil {
+		return "", fmt.Errorf("failed to write to fallback path '%s' after writing '%s' failed (%v): %w", fallback, path, writeErr, err)
+	}
+	return fallback, nil
+}
+
+func (p *fileProcessor) processAppend(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	content, err := p.writableContent(msg)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to append to '%s': %w", path, err)
+	}
+
+	if err := p.nm.FS().MkdirAll(filepath.Dir(path), p.conf.DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create directory for '%s': %w", path, err)
+	}
+	file, err := p.nm.FS().OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, p.conf.FileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s' for appending: %w", path, err)
+	}
+
+	writer, ok := file.(io.Writer)
+	if !ok {
+		file.Close()
+		return nil, errors.New("failed to open a writable file")
+	}
+	if err := writeFull(writer, content); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to append to file '%s': %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close file '%s': %w", path, err)
+	}
+
+	if err := p.syncWritten(ctx, path); err != nil {
+		return nil, err
+	}
+	return service.MessageBatch{msg}, nil
+}
+
+// contentAddressedPath returns the path within dir named after the checksum of
+// content, and whether a file already exists there.
+func (p *fileProcessor) contentAddressedPath(dir string, content []byte) (string, bool, error) {
+	hasher, err := fileProcessorNewHash(p.conf.Algorithm)
+	if err != nil {
+		return "", false, err
+	}
+	_, _ = hasher.Write(content)
+	path := filepath.Join(dir, hex.EncodeToString(hasher.Sum(nil)))
+
+	if _, err := p.nm.FS().Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return path, false, nil
+		}
+		return "", false, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+	}
+	return path, true, nil
+}
+
+// fileContentMatches returns true if the file at path exists and holds exactly
+// content. Files are only checksummed when their size matches, and are
+// streamed through the hasher rather than being read into memory.
+func (p *fileProcessor) fileContentMatches(path string, content []byte) (bool, error) {
+	fileInfo, err := p.nm.FS().Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+	}
+	if !fileInfo.Mode().IsRegular() || fileInfo.Size() != int64(len(content)) {
+		return false, nil
+	}
+
+	file, err := p.nm.FS().Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return false, fmt.Errorf("failed to read file '%s': %w", path, err)
+	}
+
+	expected := sha256.Sum256(content)
+	return bytes.Equal(hasher.Sum(nil), expected[:]), nil
+}
+
+// writeStaged writes content to a temporary file within the stage directory
+// and then atomically copies it to path, returning the time taken by each
+// step. The staged file is removed once the copy has completed or failed.
+func (p *fileProcessor) writeStaged(ctx context.Context, path string, content []byte) (writeDuration, transferDuration time.Duration, err error) {
+	start := time.Now()
+	staged, err := p.stageTempFile(filepath.Join(p.conf.StageDir, filepath.Base(path)), 0, func(w io.Writer) error {
+		return writeFull(w, content)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if rerr := p.nm.FS().Remove(staged); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+			p.log.Warnf("Failed to remove staged file '%s': %v", staged, rerr)
+		}
+	}()
+	writeDuration = time.Since(start)
+
+	start = time.Now()
+	if err := p.atomicCopy(ctx, staged, path); err != nil {
+		return writeDuration, 0, err
+	}
+	return writeDuration, time.Since(start), nil
+}
+
+// checkNotDir returns a descriptive error when path is an existing directory,
+// which would otherwise only surface as an obscure failure to rename a
+// temporary file over it.
+func (p *fileProcessor) checkNotDir(path string) error {
+	if info, err := p.nm.FS().Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("cannot write to '%s': path is a directory", path)
+	}
+	return nil
+}
+
+// syncWritten flushes the files at paths, which have just been renamed into
+// place, according to the sync_policy. With the each policy every file and its
+// directory is flushed before returning, whereas with the interval policy the
+// call blocks until the next batched flush that includes paths has completed.
+func (p *fileProcessor) syncWritten(ctx context.Context, paths ...string) error {
+	switch p.conf.SyncPolicy {
+	case fileProcessorSyncEach:
+		errs := p.syncFiles(paths)
+		for _, path := range paths {
+			if err := errs[path]; err != nil {
+				return err
+			}
+		}
+	case fileProcessorSyncInterval:
+		return p.syncer.Sync(ctx, paths...)
+	}
+	return nil
+}
+
+// syncFiles flushes the files at paths, and the directories containing them,
+// to stable storage. Any errors are returned by path.
+func (p *fileProcessor) syncFiles(paths []string) map[string]error {
+	errs := map[string]error{}
+	dirErrs := map[string]error{}
+	for _, path := range paths {
+		if err := p.syncPath(path, syncOpenFlag); err != nil {
+			errs[path] = err
+			continue
+		}
+		if !syncDirectories {
+			continue
+		}
+		dir := filepath.Dir(path)
+		dirErr, synced := dirErrs[dir]
+		if !synced {
+			dirErr = p.syncPath(dir, os.O_RDONLY)
+			dirErrs[dir] = dirErr
+		}
+		if dirErr != nil {
+			errs[path] = dirErr
+		}
+	}
+	return errs
+}
+
+// syncPath flushes the file or directory at path to stable storage. Files
+// that do not support flushing, such as those of some custom filesystems, are
+// skipped.
+func (p *fileProcessor) syncPath(path string, flag int) error {
+	file, err := p.nm.FS().OpenFile(path, flag, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s' to sync it: %w", path, err)
+	}
+	defer file.Close()
+
+	syncer, ok := file.(interface{ Sync() error })
+	if !ok {
+		return nil
+	}
+	if err := syncer.Sync(); err != nil {
+		return fmt.Errorf("failed to sync '%s': %w", path, err)
+	}
+	return nil
+}
+
+// atomicWriteFile creates the parent directories of path, calls write with a
+// temporary file next to path and then renames the temporary file into place,
+// so that readers never observe a partially written file.
+func (p *fileProcessor) atomicWriteFile(ctx context.Context, path string, write func(w io.Writer) error) error {
+	return p.atomicWriteFileFlags(ctx, path, 0, write)
+}
+
+// atomicWriteFileFlags is atomicWriteFile with additional flags used when
+// opening the temporary file.
+func (p *fileProcessor) atomicWriteFileFlags(ctx context.Context, path string, flag int, write func(w io.Writer) error) error {
+	// Use atomic write pattern: write to temp file, then rename
+	tempFile, err := p.stageTempFile(path, flag, write)
+	if err != nil {
+		return err
+	}
+
+	if err := p.renameWithRetry(ctx, tempFile, path); err != nil {
+		_ = p.nm.FS().Remove(tempFile)
+		return fmt.Errorf("failed to rename temporary file '%s' to '%s': %w", tempFile, path, err)
+	}
+	return p.syncWritten(ctx, path)
+}
+
+// stageTempFile creates the parent directories of path and calls write with a
+// new temporary file next to path, returning the name of the temporary file
+// once it has been written and closed.
+func (p *fileProcessor) stageTempFile(path string, flag int, write func(w io.Writer) error) (string, error) {
+	if err := p.checkNotDir(path); err != nil {
+		return "", err
+	}
+	if err := p.nm.FS().MkdirAll(filepath.Dir(path), p.conf.DirMode); err != nil {
+		return "", fmt.Errorf("failed to create directory for '%s': %w", path, err)
+	}
+
+	tempFile, err := generateTempFileName(path)
+	if err != nil {
+		return "", err
+	}
+	file, err := p.nm.FS().OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|flag, p.conf.FileMode)
+	if err != nil {
+		return "", fmt.Errorf("failed to open temporary file '%s' for writing: %w", tempFile, err)
+	}
+
+	writer, ok := file.(io.Writer)
+	if !ok {
+		file.Close()
+		_ = p.nm.FS().Remove(tempFile)
+		return "", errors.New("failed to open a writable file")
+	}
+
+	// Write content to temporary file
+	if err := write(writer); err != nil {
+		file.Close()
+		_ = p.nm.FS().Remove(tempFile)
+		return "", fmt.Errorf("failed to write to temporary file '%s': %w", tempFile, err)
+	}
+
+	// Close file before rename to ensure all data is flushed
+	if err := file.Close(); err != nil {
+		_ = p.nm.FS().Remove(tempFile)
+		return "", fmt.Errorf("failed to close temporary file '%s': %w", tempFile, err)
+	}
+	return tempFile, nil
+}
+
+// renameWithRetry renames oldpath to newpath, retrying up to the configured
+// number of times when the destination is momentarily busy.
+func (p *fileProcessor) renameWithRetry(ctx context.Context, oldpath, newpath string) error {
+	for attempt := 0; ; attempt++ {
+		err := p.rename(oldpath, newpath)
+		if err == nil || attempt >= p.conf.RenameRetries || !isTransientRenameError(err) {
+			return err
+		}
+		p.log.Debugf("Destination '%s' is busy, retrying rename: %v", newpath, err)
+		select {
+		case <-ctx.Done():
+			return component.ErrTimeout
+		case <-time.After(p.conf.RenameDelay):
+		}
+	}
+}
+
+func (p *fileProcessor) processDelete(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	if err := p.nm.FS().Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to delete file '%s': %w", path, err)
+	}
+
+	return service.MessageBatch{msg}, nil
+}
+
+// forEachPath calls fn for each of paths using up to the configured number of
+// workers, and returns maps of each path to whether fn succeeded and of each
+// failed path to its error. With the fail_fast error mode no further paths are
+// started after the first failure, which is returned instead.
+func (p *fileProcessor) forEachPath(ctx context.Context, paths []string, fn func(ctx context.Context, path string) error) (results, failures map[string]any, err error) {
+	results = make(map[string]any, len(paths))
+	failures = map[string]any{}
+
+	poolCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mut sync.Mutex
+	var firstErr error
+	pathChan := make(chan string)
+
+	var wg sync.WaitGroup
+	for range min(p.conf.Workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range pathChan {
+				if poolCtx.Err() != nil {
+					continue
+				}
+				err := fn(poolCtx, path)
+
+				mut.Lock()
+				results[path] = err == nil
+				if err != nil {
+					failures[path] = err.Error()
+					if p.conf.ErrorMode == fileProcessorErrModeFailFast && firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				}
+				mut.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, path := range paths {
+		select {
+		case pathChan <- path:
+		case <-poolCtx.Done():
+			break dispatch
+		}
+	}
+	close(pathChan)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, nil, component.ErrTimeout
+	}
+	return results, failures, nil
+}
+
+// queryPaths returns the cleaned list of paths returned by the paths mapping
+// for msg.
+func (p *fileProcessor) queryPaths(msg *service.Message) ([]string, error) {
+	resMsg, err := msg.BloblangQuery(p.conf.Paths)
+	if err != nil {
+		return nil, fmt.Errorf("paths mapping error: %w", err)
+	}
+	if resMsg == nil {
+		return nil, errors.New("paths mapping deleted the root")
+	}
+	res, err := resMsg.AsStructured()
+	if err != nil {
+		return nil, fmt.Errorf("paths mapping error: %w", err)
+	}
+	rawPaths, ok := res.([]any)
+	if !ok {
+		return nil, fmt.Errorf("paths mapping returned non-array value: %T", res)
+	}
+
+	paths := make([]string, len(rawPaths))
+	for i, v := range rawPaths {
+		if paths[i], ok = v.(string); !ok {
+			return nil, fmt.Errorf("paths mapping returned non-string element at index %d: %T", i, v)
+		}
+		paths[i] = filepath.Clean(paths[i])
+	}
+	return paths, nil
+}
+
+func (p *fileProcessor) processBulkDelete(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	paths, err := p.queryPaths(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	results, failures, err := p.forEachPath(ctx, paths, func(ctx context.Context, path string) error {
+		return p.nm.FS().Remove(path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_delete_results", results)
+	newMsg.MetaSetMut("file_delete_errors", failures)
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processMove(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	if p.conf.DestinationPath == nil {
+		return nil, errors.New("destination path is required for " + fileProcessorOpMove + " operation")
+	}
+
+	srcPath, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("source path interpolation error: %w", err)
+	}
+	srcPath = filepath.Clean(srcPath)
+
+	destPath, err := p.treeDestinationPath(msg, srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if srcPath == destPath {
+		return p.handleSamePath(msg, srcPath)
+	}
+	return p.atomicCopyAndDelete(ctx, srcPath, destPath, msg)
+}
+
+func (p *fileProcessor) processCopy(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	if p.conf.DestinationPath == nil {
+		return nil, errors.New("destination path is required for " + fileProcessorOpCopy + " operation")
+	}
+
+	srcPath, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("source path interpolation error: %w", err)
+	}
+	srcPath = filepath.Clean(srcPath)
+
+	destPath, err := p.conf.DestinationPath.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("destination path interpolation error: %w", err)
+	}
+	destPath = filepath.Clean(destPath)
+
+	if srcPath == destPath {
+		return p.handleSamePath(msg, srcPath)
+	}
+	if err := p.atomicCopy(ctx, srcPath, destPath); err != nil {
+		return nil, err
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_dest_path", destPath)
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processRename(msg *service.Message) (service.MessageBatch, error) {
+	if p.conf.DestinationPath == nil {
+		return nil, errors.New("destination path is required for " + fileProcessorOpRename + " operation")
+	}
+
+	srcPath, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("source path interpolation error: %w", err)
+	}
+	srcPath = filepath.Clean(srcPath)
+
+	destPath, err := p.treeDestinationPath(msg, srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if srcPath == destPath {
+		return p.handleSamePath(msg, srcPath)
+	}
+	if p.conf.PreserveTree {
+		if err := p.nm.FS().MkdirAll(filepath.Dir(destPath), p.conf.DirMode); err != nil {
+			return nil, fmt.Errorf("failed to create directory for '%s': %w", destPath, err)
+		}
+	}
+	if err := p.rename(srcPath, destPath); err != nil {
+		return nil, fmt.Errorf("failed to rename file from '%s' to '%s': %w", srcPath, destPath, err)
+	}
+
+	return service.MessageBatch{msg}, nil
+}
+
+// errExchangeUnsupported is returned by exchangeFiles when an atomic exchange
+// of two files is not supported.
+var errExchangeUnsupported = errors.New("atomic exchange of files is not supported")
+
+func (p *fileProcessor) processSwap(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	destPath, err := p.conf.DestinationPath.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("destination path interpolation error: %w", err)
+	}
+	destPath = filepath.Clean(destPath)
+
+	if path == destPath {
+		return nil, fmt.Errorf("cannot swap '%s' with itself", path)
+	}
+	for _, ep := range []string{path, destPath} {
+		if _, err := p.nm.FS().Stat(ep); err != nil {
+			return nil, fmt.Errorf("failed to swap '%s' and '%s': %w", path, destPath, err)
+		}
+	}
+
+	err = exchangeFiles(path, destPath)
+	if errors.Is(err, errExchangeUnsupported) {
+		err = p.swapViaTempFile(path, destPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to swap '%s' and '%s': %w", path, destPath, err)
+	}
+	return service.MessageBatch{msg}, nil
+}
+
+// swapViaTempFile exchanges the files at path and destPath with three renames,
+// moving destPath aside to a temporary name first. Should a later rename fail
+// the earlier renames are reverted.
+func (p *fileProcessor) swapViaTempFile(path, destPath string) error {
+	tempFile, err := generateTempFileName(destPath)
+	if err != nil {
+		return err
+	}
+	if err := p.rename(destPath, tempFile); err != nil {
+		return err
+	}
+	if err := p.rename(path, destPath); err != nil {
+		_ = p.rename(tempFile, destPath)
+		return err
+	}
+	if err := p.rename(tempFile, path); err != nil {
+		_ = p.rename(destPath, path)
+		_ = p.rename(tempFile, destPath)
+		return err
+	}
+	return nil
+}
+
+// treeDestinationPath returns the destination of srcPath for a move or rename,
+// which is beneath the destination directory at the location of srcPath
+// relative to the source base when preserve_tree is enabled.
+func (p *fileProcessor) treeDestinationPath(msg *service.Message, srcPath string) (string, error) {
+	destPath, err := p.conf.DestinationPath.TryString(msg)
+	if err != nil {
+		return "", fmt.Errorf("destination path interpolation error: %w", err)
+	}
+	destPath = filepath.Clean(destPath)
+	if !p.conf.PreserveTree {
+		return destPath, nil
+	}
+
+	base, err := p.conf.SourceBase.TryString(msg)
+	if err != nil {
+		return "", fmt.Errorf("source base interpolation error: %w", err)
+	}
+	rel, err := filepath.Rel(filepath.Clean(base), srcPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%s' is not within source base '%s'", srcPath, base)
+	}
+	return filepath.Join(destPath, rel), nil
+}
+
+// handleSamePath applies the configured same_path_behavior to a move, copy or
+// rename whose source and destination are both path.
+func (p *fileProcessor) handleSamePath(msg *service.Message, path string) (service.MessageBatch, error) {
+	if p.conf.SamePath == fileProcessorSamePathError {
+		return nil, fmt.Errorf("source and destination paths are both '%s'", path)
+	}
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_skipped", true)
+	return service.MessageBatch{newMsg}, nil
+}
+
+// atomicCopyAndDelete performs an atomic copy from src to dest and then deletes src.
+// This ensures that either the operation completes fully or leaves the source intact.
+func (p *fileProcessor) atomicCopyAndDelete(ctx context.Context, srcPath, destPath string, msg *service.Message) (service.MessageBatch, error) {
+	if err := p.atomicCopy(ctx, srcPath, destPath); err != nil {
+		return nil, err
+	}
+
+	// Delete the source now that the destination is complete. Retry with backoff
+	// to handle transient file locks that are common on Windows (e.g. an external
+	// process that briefly holds the file open after writing it).
+	const maxDeleteRetries = 5
+	var removeErr error
+retryLoop:
+	for attempt := 1; attempt <= maxDeleteRetries; attempt++ {
+		removeErr = p.nm.FS().Remove(srcPath)
+		if removeErr == nil {
+			break
+		}
+		if attempt < maxDeleteRetries {
+			select {
+			case <-ctx.Done():
+				break retryLoop
+			case <-time.After(time.Duration(attempt) * p.deleteRetryBackoff):
+			}
+		}
+	}
+	if removeErr != nil {
+		if p.conf.OnDeleteFailure == fileProcessorOnDelFailError {
+			return nil, fmt.Errorf("failed to delete source file '%s' after successful copy to '%s': %w", srcPath, destPath, removeErr)
+		}
+
+		// The copy succeeded so data is safe, but log an error so operators are
+		// aware of the orphaned source file that will need manual cleanup.
+		p.log.Errorf("Failed to delete source file '%s' after successful copy to '%s': %v", srcPath, destPath, removeErr)
+
+		if p.conf.OnDeleteFailure == fileProcessorOnDelFailMetadata {
+			newMsg := msg.Copy()
+			newMsg.MetaSetMut("file_source_delete_failed", true)
+			return service.MessageBatch{newMsg}, nil
+		}
+	}
+
+	return service.MessageBatch{msg}, nil
+}
+
+// atomicCopy streams the file at srcPath into a temporary file beside destPath
+// and then renames it into place, such that destPath is only ever observed
+// complete.
+func (p *fileProcessor) atomicCopy(ctx context.Context, srcPath, destPath string) error {
+	if err := p.checkNotDir(destPath); err != nil {
+		return err
+	}
+	if err := p.nm.FS().MkdirAll(filepath.Dir(destPath), p.conf.DirMode); err != nil {
+		return fmt.Errorf("failed to create directory for '%s': %w", destPath, err)
+	}
+
+	tempFile, err := generateTempFileName(destPath)
+	if err != nil {
+		return err
+	}
+	srcFile, err := p.nm.FS().Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file '%s': %w", srcPath, err)
+	}
+
+	destFile, err := p.nm.FS().OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, p.conf.FileMode)
+	if err != nil {
+		srcFile.Close()
+		return fmt.Errorf("failed to open temporary destination file '%s': %w", tempFile, err)
+	}
+
+	writer, ok := destFile.(io.Writer)
+	if !ok {
+		srcFile.Close()
+		destFile.Close()
+		_ = p.nm.FS().Remove(tempFile)
+		return errors.New("failed to open a writable destination file")
+	}
+
+	if _, err := io.Copy(writer, srcFile); err != nil {
+		srcFile.Close()
+		destFile.Close()
+		_ = p.nm.FS().Remove(tempFile)
+		return fmt.Errorf("failed to write to temporary destination file '%s': %w", tempFile, err)
+	}
+
+	// Close source before the rename and remove steps. On Windows, DeleteFile fails
+	// if the calling process still holds a handle open on the file.
+	srcFile.Close()
+
+	if err := destFile.Close(); err != nil {
+		_ = p.nm.FS().Remove(tempFile)
+		return fmt.Errorf("failed to close temporary destination file '%s': %w", tempFile, err)
+	}
+
+	if err := p.renameWithRetry(ctx, tempFile, destPath); err != nil {
+		_ = p.nm.FS().Remove(tempFile)
+		return fmt.Errorf("failed to rename temporary file '%s' to '%s': %w", tempFile, destPath, err)
+	}
+	return p.syncWritten(ctx, destPath)
+}
+
+func (p *fileProcessor) processStat(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	fileInfo, err := p.nm.FS().Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+	}
+
+	newMsg := msg.Copy()
+
+	addFileMetadata(newMsg, path, fileInfo)
+	if inode, device, ok := fileIdentity(fileInfo); ok {
+		newMsg.MetaSetMut("file_inode", int64(inode))
+		newMsg.MetaSetMut("file_device", int64(device))
+	}
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processCounter(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	p.counterMut.Lock()
+	defer p.counterMut.Unlock()
+
+	var value int64
+	content, err := ifs.ReadFile(p.nm.FS(), path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read counter '%s': %w", path, err)
+	}
+	if trimmed := strings.TrimSpace(string(content)); trimmed != "" {
+		if value, err = strconv.ParseInt(trimmed, 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse counter '%s': %w", path, err)
+		}
+	}
+
+	if (p.conf.Step > 0 && value > math.MaxInt64-p.conf.Step) || (p.conf.Step < 0 && value < math.MinInt64-p.conf.Step) {
+		return nil, fmt.Errorf("counter '%s' would overflow", path)
+	}
+	value += p.conf.Step
+
+	if err := p.atomicWriteFile(ctx, path, func(w io.Writer) error {
+		return writeFull(w, []byte(strconv.FormatInt(value, 10)+"\n"))
+	}); err != nil {
+		return nil, err
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_counter_value", value)
+	return service.MessageBatch{newMsg}, nil
+}
+
+// validateContent returns an error when the content of msg fails the
+// configured validation.
+func (p *fileProcessor) validateContent(msg *service.Message, content []byte) error {
+	switch p.conf.Validate {
+	case fileProcessorValidateJSON:
+		if !json.Valid(content) {
+			return errors.New("content is not valid JSON")
+		}
+	case fileProcessorValidateXML:
+		if err := validateXML(content); err != nil {
+			return fmt.Errorf("content is not valid XML: %w", err)
+		}
+	}
+	if p.conf.ValidateCheck == nil {
+		return nil
+	}
+
+	resMsg, err := msg.BloblangQuery(p.conf.ValidateCheck)
+	if err != nil {
+		return fmt.Errorf("validate check error: %w", err)
+	}
+	var res any
+	if resMsg != nil {
+		if res, err = resMsg.AsStructured(); err != nil {
+			return fmt.Errorf("validate check error: %w", err)
+		}
+	}
+	valid, ok := res.(bool)
+	if !ok {
+		return fmt.Errorf("validate check returned non-boolean value: %T", res)
+	}
+	if !valid {
+		return errors.New("content failed validate check")
+	}
+	return nil
+}
+
+func (p *fileProcessor) processWatchSize(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	ticker := time.NewTicker(p.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		fileInfo, err := p.nm.FS().Stat(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+		}
+		if err == nil && fileInfo.Size() >= p.conf.SizeThreshold {
+			newMsg := msg.Copy()
+			addFileMetadata(newMsg, path, fileInfo)
+			return service.MessageBatch{newMsg}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, component.ErrTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *fileProcessor) processChecksum(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	hasher, err := fileProcessorNewHash(p.conf.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := p.nm.FS().Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, fmt.Errorf("failed to read file '%s': %w", path, err)
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", path)
+	newMsg.MetaSetMut("file_checksum", hex.EncodeToString(hasher.Sum(nil)))
+	newMsg.MetaSetMut("file_checksum_algorithm", p.conf.Algorithm)
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processVerify(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	expected, err := p.conf.Expected.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("expected checksum interpolation error: %w", err)
+	}
+
+	hasher, err := fileProcessorNewHash(p.conf.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := p.nm.FS().Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, fmt.Errorf("failed to read file '%s': %w", path, err)
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	valid := strings.EqualFold(checksum, strings.TrimSpace(expected))
+	if !valid && p.conf.FailOnMismatch {
+		return nil, fmt.Errorf("checksum mismatch for file '%s': expected %s, got %s", path, expected, checksum)
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", path)
+	newMsg.MetaSetMut("file_checksum", checksum)
+	newMsg.MetaSetMut("file_checksum_valid", valid)
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processTreeChecksum(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	root, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	root = filepath.Clean(root)
+
+	type fileDigest struct {
+		rel    string
+		digest string
+	}
+	var digests []fileDigest
+	if err := p.walkFiles(ctx, root, func(path string, info fs.FileInfo) error {
+		rel, err := filepath.Rel(root, path)
 		if err != nil {
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				return nil, component.ErrTimeout
+			return err
+		}
+		hasher, err := fileProcessorNewHash(p.conf.Algorithm)
+		if err != nil {
+			return err
+		}
+		file, err := p.nm.FS().Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open file '%s': %w", path, err)
+		}
+		defer file.Close()
+		if _, err := io.Copy(hasher, file); err != nil {
+			return fmt.Errorf("failed to read file '%s': %w", path, err)
+		}
+		digests = append(digests, fileDigest{
+			rel:    filepath.ToSlash(rel),
+			digest: hex.EncodeToString(hasher.Sum(nil)),
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(digests, func(a, b fileDigest) int {
+		return strings.Compare(a.rel, b.rel)
+	})
+
+	tree, err := fileProcessorNewHash(p.conf.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range digests {
+		// The relative path and digest are separated by a NUL byte since it
+		// cannot appear within a path, which keeps the encoding unambiguous.
+		_, _ = io.WriteString(tree, d.rel+"\x00"+d.digest+"\n")
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", root)
+	newMsg.MetaSetMut("dir_checksum", hex.EncodeToString(tree.Sum(nil)))
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processList(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	dir, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	dir = filepath.Clean(dir)
+
+	var batch service.MessageBatch
+	if err := p.listDir(ctx, dir, func(path string, info fs.FileInfo) {
+		newMsg := msg.Copy()
+		newMsg.SetBytes([]byte(path))
+		addFileMetadata(newMsg, path, info)
+		batch = append(batch, newMsg)
+	}); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// listDir calls fn for each entry of the directory dir, descending into
+// subdirectories after calling fn for them when recursive is enabled.
+func (p *fileProcessor) listDir(ctx context.Context, dir string, fn func(path string, info fs.FileInfo)) error {
+	if err := ctx.Err(); err != nil {
+		return component.ErrTimeout
+	}
+
+	f, err := p.nm.FS().Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory '%s': %w", dir, err)
+	}
+	dirFile, ok := f.(fs.ReadDirFile)
+	if !ok {
+		f.Close()
+		return fmt.Errorf("failed to read directory '%s': not a directory", dir)
+	}
+	entries, err := dirFile.ReadDir(-1)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to get file info for '%s': %w", path, err)
+		}
+		fn(path, info)
+		if p.conf.Recursive && entry.IsDir() {
+			if err := p.listDir(ctx, path, fn); err != nil {
+				return err
 			}
-			if err == io.EOF {
-				// End of file reached
-				break
+		}
+	}
+	return nil
+}
+
+// walkFiles recursively walks the directory dir, calling fn for each regular
+// file found beneath it. Symbolic links and other irregular files are skipped.
+func (p *fileProcessor) walkFiles(ctx context.Context, dir string, fn func(path string, info fs.FileInfo) error) error {
+	if err := ctx.Err(); err != nil {
+		return component.ErrTimeout
+	}
+
+	f, err := p.nm.FS().Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory '%s': %w", dir, err)
+	}
+	dirFile, ok := f.(fs.ReadDirFile)
+	if !ok {
+		f.Close()
+		return fmt.Errorf("failed to read directory '%s': not a directory", dir)
+	}
+	entries, err := dirFile.ReadDir(-1)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if err := p.walkFiles(ctx, path, fn); err != nil {
+				return err
 			}
-			return nil, fmt.Errorf("failed to read from scanner for file '%s': %w", path, err)
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to get file info for '%s': %w", path, err)
 		}
+		if err := fn(path, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *fileProcessor) processDelta(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	content, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := ifs.ReadFile(p.nm.FS(), path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read file '%s': %w", path, err)
+	}
+
+	if limit := p.conf.MaxDiffLines; limit > 0 {
+		if lines := countLines(existing); lines > limit {
+			return nil, fmt.Errorf("refusing to diff '%s' as it has %d lines, which exceeds %v of %d", path, lines, fileProcessorFieldDiffLines, limit)
+		}
+		if lines := countLines(content); lines > limit {
+			return nil, fmt.Errorf("refusing to diff '%s' as the message content has %d lines, which exceeds %v of %d", path, lines, fileProcessorFieldDiffLines, limit)
+		}
+	}
+
+	diff, added, removed := unifiedDiff(path, path, existing, content, 3)
+
+	newMsg := msg.Copy()
+	if p.conf.DestinationPath != nil {
+		destPath, err := p.conf.DestinationPath.TryString(msg)
+		if err != nil {
+			return nil, fmt.Errorf("destination path interpolation error: %w", err)
+		}
+		destPath = filepath.Clean(destPath)
+
+		if err := p.atomicWriteFile(ctx, destPath, func(w io.Writer) error {
+			return writeFull(w, diff)
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		newMsg.SetBytes(diff)
+	}
+	newMsg.MetaSetMut("file_path", path)
+	newMsg.MetaSetMut("file_delta_changed", added+removed > 0)
+	newMsg.MetaSetMut("file_delta_lines_added", int64(added))
+	newMsg.MetaSetMut("file_delta_lines_removed", int64(removed))
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+// fileProcessorVersionLayout is the time layout of the suffix of versions
+// written by publish_versioned, which sorts in the order they were written.
+const fileProcessorVersionLayout = "20060102T150405.000000000Z"
+
+func (p *fileProcessor) processPublishVersioned(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
+	}
+	path = filepath.Clean(path)
+
+	if info, err := p.nm.FS().Lstat(path); err == nil && info.Mode()&fs.ModeSymlink == 0 {
+		return nil, fmt.Errorf("refusing to publish to '%s' as it is not a symbolic link", path)
+	} else if errors.Is(err, errors.ErrUnsupported) {
+		return nil, fmt.Errorf("the %v operation requires a filesystem that supports symbolic links: %w", fileProcessorOpPublish, err)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
+	}
+
+	content, err := p.writableContent(msg)
+	if err != nil {
+		return nil, fmt.Errorf("refusing to publish '%s': %w", path, err)
+	}
+
+	versionPath := path + ".v" + time.Now().UTC().Format(fileProcessorVersionLayout)
+	if err := p.writeContent(ctx, versionPath, content); err != nil {
+		return nil, err
+	}
+
+	// Link relatively so that the directory can be relocated as a whole
+	tempLink, err := generateTempFileName(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.nm.FS().Symlink(filepath.Base(versionPath), tempLink); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			_ = p.nm.FS().Remove(versionPath)
+			return nil, fmt.Errorf("the %v operation requires a filesystem that supports symbolic links: %w", fileProcessorOpPublish, err)
+		}
+		return nil, fmt.Errorf("failed to create symbolic link to '%s': %w", versionPath, err)
+	}
+	if err := p.rename(tempLink, path); err != nil {
+		_ = p.nm.FS().Remove(tempLink)
+		return nil, fmt.Errorf("failed to replace symbolic link '%s': %w", path, err)
+	}
+	if err := p.syncWritten(ctx, path); err != nil {
+		return nil, err
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", path)
+	newMsg.MetaSetMut("file_version_path", versionPath)
+	newMsg.MetaSetMut("file_versions_pruned", int64(p.pruneVersions(path, versionPath)))
+	return service.MessageBatch{newMsg}, nil
+}
+
+// pruneVersions removes all but the newest keep_versions versions published
+// to path, never removing current, and returns the number removed. Failures
+// are logged as the publish itself has already succeeded.
+func (p *fileProcessor) pruneVersions(path, current string) int {
+	if p.conf.KeepVersions == 0 {
+		return 0
+	}
 
-		// Create a copy of the original message for each part
-		for _, part := range parts {
-			newMsg := msg.Copy()
-			partBytes, err := part.AsBytes()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get bytes from part: %w", err)
-			}
-			newMsg.SetBytes(partBytes)
-			addFileMetadata(newMsg, path, fileInfo)
+	dir := filepath.Dir(path)
+	entries, err := fs.ReadDir(p.nm.FS(), dir)
+	if err != nil {
+		p.log.Warnf("Failed to read directory '%s' to prune versions: %v", dir, err)
+		return 0
+	}
 
-			allMessages = append(allMessages, newMsg)
+	prefix := filepath.Base(path) + ".v"
+	var versions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) {
+			continue
 		}
+		if _, err := time.Parse(fileProcessorVersionLayout, name[len(prefix):]); err != nil {
+			continue
+		}
+		versions = append(versions, filepath.Join(dir, name))
 	}
+	slices.Sort(versions)
 
-	// If no messages were created (empty file), create one with just metadata
-	if len(allMessages) == 0 {
-		newMsg := msg.Copy()
-		addFileMetadata(newMsg, path, fileInfo)
-		return service.MessageBatch{newMsg}, nil
+	var pruned int
+	for _, version := range versions[:max(len(versions)-p.conf.KeepVersions, 0)] {
+		if version == current {
+			continue
+		}
+		if err := p.nm.FS().Remove(version); err != nil {
+			p.log.Warnf("Failed to remove old version '%s': %v", version, err)
+			continue
+		}
+		pruned++
 	}
-
-	return allMessages, nil
+	return pruned
 }
 
-func (p *fileProcessor) processWrite(msg *service.Message) (service.MessageBatch, error) {
+func (p *fileProcessor) processGetACL(msg *service.Message) (service.MessageBatch, error) {
 	path, err := p.conf.Path.TryString(msg)
 	if err != nil {
 		return nil, fmt.Errorf("path interpolation error: %w", err)
 	}
 	path = filepath.Clean(path)
 
-	content, err := msg.AsBytes()
+	entries, err := p.readACL(path)
 	if err != nil {
-		return nil, err
+		return p.handleACLError(msg, err)
 	}
 
-	if err := p.nm.FS().MkdirAll(filepath.Dir(path), fs.FileMode(0o777)); err != nil {
-		return nil, fmt.Errorf("failed to create directory for '%s': %w", path, err)
-	}
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", path)
+	newMsg.MetaSetMut("file_acl", entries)
+	return service.MessageBatch{newMsg}, nil
+}
 
-	// Use atomic write pattern: write to temp file, then rename
-	tempFile, err := generateTempFileName(path)
+// readACL returns the entries of the access ACL of the file at path.
+func (p *fileProcessor) readACL(path string) ([]any, error) {
+	fileInfo, err := p.nm.FS().Stat(path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get file info for '%s': %w", path, err)
 	}
-	file, err := p.nm.FS().OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(0o666))
+
+	data, err := getFileACL(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open temporary file '%s' for writing: %w", tempFile, err)
+		return nil, fmt.Errorf("failed to read ACL of '%s': %w", path, err)
+	}
+	if data == nil {
+		return minimalPOSIXACL(fileInfo.Mode()), nil
 	}
 
-	writer, ok := file.(io.Writer)
-	if !ok {
-		file.Close()
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, errors.New("failed to open a writable file")
+	entries, err := decodePOSIXACL(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ACL of '%s': %w", path, err)
 	}
+	return entries, nil
+}
 
-	// Write content to temporary file
-	if _, err := writer.Write(content); err != nil {
-		file.Close()
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, fmt.Errorf("failed to write to temporary file '%s': %w", tempFile, err)
+func (p *fileProcessor) processSetACL(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
 	}
+	path = filepath.Clean(path)
 
-	// Close file before rename to ensure all data is flushed
-	if err := file.Close(); err != nil {
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, fmt.Errorf("failed to close temporary file '%s': %w", tempFile, err)
+	structured, err := msg.AsStructured()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ACL for '%s': %w", path, err)
+	}
+	entries, ok := structured.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected ACL for '%s' to be an array, got %T", path, structured)
+	}
+	data, err := encodePOSIXACL(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ACL for '%s': %w", path, err)
 	}
 
-	if err := os.Rename(tempFile, path); err != nil {
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, fmt.Errorf("failed to rename temporary file '%s' to '%s': %w", tempFile, path, err)
+	if err := setFileACL(path, data); err != nil {
+		return p.handleACLError(msg, fmt.Errorf("failed to write ACL of '%s': %w", path, err))
 	}
 
-	return service.MessageBatch{msg}, nil
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", path)
+	return service.MessageBatch{newMsg}, nil
 }
 
-func (p *fileProcessor) processDelete(msg *service.Message) (service.MessageBatch, error) {
+// handleACLError fails with err or, when fail_on_error is disabled, reports it
+// in the metadata of msg.
+func (p *fileProcessor) handleACLError(msg *service.Message, err error) (service.MessageBatch, error) {
+	if p.conf.FailOnACLError {
+		return nil, err
+	}
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_acl_error", err.Error())
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processPatch(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
 	path, err := p.conf.Path.TryString(msg)
 	if err != nil {
 		return nil, fmt.Errorf("path interpolation error: %w", err)
 	}
 	path = filepath.Clean(path)
 
-	if err := p.nm.FS().Remove(path); err != nil {
-		return nil, fmt.Errorf("failed to delete file '%s': %w", path, err)
+	diff, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
 	}
-
-	return service.MessageBatch{msg}, nil
-}
-
-func (p *fileProcessor) processMove(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
-	if p.conf.DestinationPath == nil {
-		return nil, errors.New("destination path is required for " + fileProcessorOpMove + " operation")
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse patch for '%s': %w", path, err)
 	}
 
-	srcPath, err := p.conf.Path.TryString(msg)
+	file, err := p.nm.FS().Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("source path interpolation error: %w", err)
+		return nil, fmt.Errorf("failed to open file '%s': %w", path, err)
 	}
-	srcPath = filepath.Clean(srcPath)
-
-	destPath, err := p.conf.DestinationPath.TryString(msg)
+	content, err := io.ReadAll(file)
+	file.Close()
 	if err != nil {
-		return nil, fmt.Errorf("destination path interpolation error: %w", err)
+		return nil, fmt.Errorf("failed to read file '%s': %w", path, err)
 	}
-	destPath = filepath.Clean(destPath)
 
-	return p.atomicCopyAndDelete(ctx, srcPath, destPath, msg)
-}
+	patched, applied, failed, err := applyUnifiedDiff(content, hunks, p.conf.OnConflict == fileProcessorOnConflictSkip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch file '%s': %w", path, err)
+	}
 
-func (p *fileProcessor) processRename(msg *service.Message) (service.MessageBatch, error) {
-	if p.conf.DestinationPath == nil {
-		return nil, errors.New("destination path is required for " + fileProcessorOpRename + " operation")
+	if applied > 0 {
+		if err := p.atomicWriteFile(ctx, path, func(w io.Writer) error {
+			return writeFull(w, patched)
+		}); err != nil {
+			return nil, err
+		}
 	}
 
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_path", path)
+	newMsg.MetaSetMut("file_patch_hunks_applied", int64(applied))
+	newMsg.MetaSetMut("file_patch_hunks_failed", int64(failed))
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processReflow(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
 	srcPath, err := p.conf.Path.TryString(msg)
 	if err != nil {
 		return nil, fmt.Errorf("source path interpolation error: %w", err)
@@ -350,97 +3926,140 @@ func (p *fileProcessor) processRename(msg *service.Message) (service.MessageBatc
 	}
 	destPath = filepath.Clean(destPath)
 
-	if err := os.Rename(srcPath, destPath); err != nil {
-		return nil, fmt.Errorf("failed to rename file from '%s' to '%s': %w", srcPath, destPath, err)
+	file, err := p.nm.FS().Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", srcPath, err)
 	}
+	defer file.Close()
 
-	return service.MessageBatch{msg}, nil
-}
+	reader := p.bufferReads(file)
+	if p.conf.BOM == fileProcessorBOMStrip {
+		reader, _ = stripBOM(reader)
+	}
 
-// atomicCopyAndDelete performs an atomic copy from src to dest and then deletes src.
-// This ensures that either the operation completes fully or leaves the source intact.
-func (p *fileProcessor) atomicCopyAndDelete(ctx context.Context, srcPath, destPath string, msg *service.Message) (service.MessageBatch, error) {
-	if err := p.nm.FS().MkdirAll(filepath.Dir(destPath), fs.FileMode(0o777)); err != nil {
-		return nil, fmt.Errorf("failed to create directory for '%s': %w", destPath, err)
+	var written int64
+	if err := p.atomicWriteFile(ctx, destPath, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		if err := p.readRecords(ctx, fileProcessorDecoder(p.conf.Decode, reader), srcPath, func(record []byte, _ int64) error {
+			written++
+			return writeCodecRecord(bw, p.conf.OutputCodec, record)
+		}); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}); err != nil {
+		return nil, err
 	}
 
-	tempFile, err := generateTempFileName(destPath)
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_records_written", written)
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processRestore(msg *service.Message) (service.MessageBatch, error) {
+	path, err := p.conf.Path.TryString(msg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("path interpolation error: %w", err)
 	}
-	srcFile, err := p.nm.FS().Open(srcPath)
+	path = filepath.Clean(path)
+
+	content, err := ifs.ReadFile(p.nm.FS(), path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open source file '%s': %w", srcPath, err)
+		return nil, fmt.Errorf("failed to read file '%s': %w", path, err)
 	}
 
-	destFile, err := p.nm.FS().OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(0o666))
+	newMsg := msg.Copy()
+	newMsg.SetBytes(content)
+
+	sidecarPath := path + fileProcessorSidecarSuffix
+	sidecar, err := ifs.ReadFile(p.nm.FS(), sidecarPath)
 	if err != nil {
-		srcFile.Close()
-		return nil, fmt.Errorf("failed to open temporary destination file '%s': %w", tempFile, err)
+		if errors.Is(err, fs.ErrNotExist) {
+			return service.MessageBatch{newMsg}, nil
+		}
+		return nil, fmt.Errorf("failed to read metadata sidecar '%s': %w", sidecarPath, err)
 	}
 
-	writer, ok := destFile.(io.Writer)
-	if !ok {
-		srcFile.Close()
-		destFile.Close()
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, errors.New("failed to open a writable destination file")
+	var meta map[string]any
+	if err := json.Unmarshal(sidecar, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata sidecar '%s': %w", sidecarPath, err)
+	}
+	for k, v := range meta {
+		newMsg.MetaSetMut(k, v)
 	}
 
-	if _, err := io.Copy(writer, srcFile); err != nil {
-		srcFile.Close()
-		destFile.Close()
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, fmt.Errorf("failed to write to temporary destination file '%s': %w", tempFile, err)
+	return service.MessageBatch{newMsg}, nil
+}
+
+func (p *fileProcessor) processMktemp(msg *service.Message) (service.MessageBatch, error) {
+	dir, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("path interpolation error: %w", err)
 	}
+	dir = filepath.Clean(dir)
 
-	// Close source before the rename and remove steps. On Windows, DeleteFile fails
-	// if the calling process still holds a handle open on the file.
-	srcFile.Close()
+	pattern, err := p.conf.Pattern.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("pattern interpolation error: %w", err)
+	}
+	if strings.ContainsRune(pattern, os.PathSeparator) || strings.ContainsRune(pattern, '/') {
+		return nil, fmt.Errorf("pattern '%s' contains a path separator", pattern)
+	}
 
-	if err := destFile.Close(); err != nil {
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, fmt.Errorf("failed to close temporary destination file '%s': %w", tempFile, err)
+	content, err := msg.AsBytes()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := os.Rename(tempFile, destPath); err != nil {
-		_ = p.nm.FS().Remove(tempFile)
-		return nil, fmt.Errorf("failed to rename temporary file '%s' to '%s': %w", tempFile, destPath, err)
+	if err := p.nm.FS().MkdirAll(dir, p.conf.DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create directory '%s': %w", dir, err)
 	}
 
-	// Delete the source now that the destination is complete. Retry with backoff
-	// to handle transient file locks that are common on Windows (e.g. an external
-	// process that briefly holds the file open after writing it).
-	const maxDeleteRetries = 5
-	var removeErr error
-	for attempt := 1; attempt <= maxDeleteRetries; attempt++ {
-		removeErr = p.nm.FS().Remove(srcPath)
-		if removeErr == nil {
+	// Mirror os.CreateTemp, retrying with a new random name when we collide
+	// with an existing file.
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndexByte(pattern, '*'); i != -1 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	const maxCreateAttempts = 10000
+	var path string
+	var file fs.File
+	for attempt := 0; attempt < maxCreateAttempts; attempt++ {
+		randomSuffix, err := generateRandomHex()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, prefix+randomSuffix+suffix)
+		if file, err = p.nm.FS().OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fs.FileMode(0o600)); err == nil {
 			break
 		}
-		if attempt < maxDeleteRetries {
-			select {
-			case <-ctx.Done():
-				break
-			case <-time.After(time.Duration(attempt) * time.Second):
-			}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to create temporary file in '%s': %w", dir, err)
 		}
 	}
-	if removeErr != nil {
-		// The copy succeeded so data is safe, but log an error so operators are
-		// aware of the orphaned source file that will need manual cleanup.
-		p.log.Errorf("Failed to delete source file '%s' after successful copy to '%s': %v", srcPath, destPath, removeErr)
+	if file == nil {
+		return nil, fmt.Errorf("failed to create a unique temporary file in '%s'", dir)
 	}
 
-	return service.MessageBatch{msg}, nil
-}
+	writer, ok := file.(io.Writer)
+	if !ok {
+		file.Close()
+		_ = p.nm.FS().Remove(path)
+		return nil, errors.New("failed to open a writable file")
+	}
 
-func (p *fileProcessor) processStat(msg *service.Message) (service.MessageBatch, error) {
-	path, err := p.conf.Path.TryString(msg)
-	if err != nil {
-		return nil, fmt.Errorf("path interpolation error: %w", err)
+	if err := writeFull(writer, content); err != nil {
+		file.Close()
+		_ = p.nm.FS().Remove(path)
+		return nil, fmt.Errorf("failed to write to temporary file '%s': %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		_ = p.nm.FS().Remove(path)
+		return nil, fmt.Errorf("failed to close temporary file '%s': %w", path, err)
 	}
-	path = filepath.Clean(path)
 
 	fileInfo, err := p.nm.FS().Stat(path)
 	if err != nil {
@@ -448,12 +4067,94 @@ func (p *fileProcessor) processStat(msg *service.Message) (service.MessageBatch,
 	}
 
 	newMsg := msg.Copy()
-
 	addFileMetadata(newMsg, path, fileInfo)
 
 	return service.MessageBatch{newMsg}, nil
 }
 
+func (p *fileProcessor) processFilter(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	srcPath, err := p.conf.Path.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("source path interpolation error: %w", err)
+	}
+	srcPath = filepath.Clean(srcPath)
+
+	destPath, err := p.conf.DestinationPath.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("destination path interpolation error: %w", err)
+	}
+	destPath = filepath.Clean(destPath)
+
+	srcFile, err := p.nm.FS().Open(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file '%s': %w", srcPath, err)
+	}
+	defer srcFile.Close()
+
+	var kept, dropped int64
+	if err := p.atomicWriteFile(ctx, destPath, func(w io.Writer) error {
+		reader := bufio.NewReader(srcFile)
+		bufWriter := bufio.NewWriter(w)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			line, readErr := reader.ReadBytes('\n')
+			if readErr != nil && readErr != io.EOF {
+				return fmt.Errorf("failed to read from source file '%s': %w", srcPath, readErr)
+			}
+			if len(line) > 0 {
+				line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+
+				lineMsg := msg.Copy()
+				lineMsg.SetBytes(line)
+
+				resMsg, err := lineMsg.BloblangQuery(p.conf.Predicate)
+				if err != nil {
+					return fmt.Errorf("predicate error: %w", err)
+				}
+				var res any
+				if resMsg != nil {
+					if res, err = resMsg.AsStructured(); err != nil {
+						return fmt.Errorf("predicate error: %w", err)
+					}
+				}
+				keep, ok := res.(bool)
+				if !ok {
+					return fmt.Errorf("predicate returned non-boolean value: %T", res)
+				}
+
+				if keep {
+					kept++
+					if err := writeFull(bufWriter, append(line, '\n')); err != nil {
+						return err
+					}
+				} else {
+					dropped++
+				}
+			}
+			if readErr == io.EOF {
+				break
+			}
+		}
+		return bufWriter.Flush()
+	}); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, component.ErrTimeout
+		}
+		return nil, err
+	}
+
+	newMsg := msg.Copy()
+	newMsg.MetaSetMut("file_lines_kept", kept)
+	newMsg.MetaSetMut("file_lines_dropped", dropped)
+
+	return service.MessageBatch{newMsg}, nil
+}
+
+// addFileMetadata sets the standard file_* metadata fields of fileInfo on msg.
+// Values retain their native types rather than being converted to strings.
 func addFileMetadata(msg *service.Message, path string, fileInfo fs.FileInfo) {
 	msg.MetaSetMut("file_path", path)
 	msg.MetaSetMut("file_size", fileInfo.Size())
@@ -462,20 +4163,98 @@ func addFileMetadata(msg *service.Message, path string, fileInfo fs.FileInfo) {
 	msg.MetaSetMut("file_name", fileInfo.Name())
 	msg.MetaSetMut("file_is_dir", fileInfo.IsDir())
 	msg.MetaSetMut("file_mode", fileInfo.Mode().String())
+
+	mode := fileInfo.Mode()
+	msg.MetaSetMut("file_mode_octal", fmt.Sprintf("%04o", unixPermBits(mode)))
+	msg.MetaSetMut("file_world_writable", mode.Perm()&0o002 != 0)
+	msg.MetaSetMut("file_setuid", mode&fs.ModeSetuid != 0)
+	msg.MetaSetMut("file_setgid", mode&fs.ModeSetgid != 0)
+}
+
+// sameFile reports whether a and b describe the same file. Files described by
+// a custom filesystem without the information the os package uses to identify
+// them are assumed to match, since they cannot be told apart.
+func sameFile(a, b fs.FileInfo) bool {
+	if !os.SameFile(a, a) || !os.SameFile(b, b) {
+		return true
+	}
+	return os.SameFile(a, b)
+}
+
+// unixPermBits returns the permission bits of mode in their Unix layout, where
+// the setuid, setgid and sticky bits sit directly above the permissions.
+func unixPermBits(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+// writeFull writes all of content to w. The io.Writer contract requires an
+// error for short writes but custom service.FS implementations may not honour
+// it, so any partial progress is retried and a write that makes no progress
+// at all is reported as io.ErrShortWrite.
+func writeFull(w io.Writer, content []byte) error {
+	for len(content) > 0 {
+		n, err := w.Write(content)
+		if err != nil {
+			return err
+		}
+		if n <= 0 || n > len(content) {
+			return io.ErrShortWrite
+		}
+		content = content[n:]
+	}
+	return nil
 }
 
-// generateTempFileName generates a unique temporary file name to avoid collisions
+// errReadLimitReached is returned by a record callback in order to stop reading
+// once the configured limit of records has been emitted.
+var errReadLimitReached = errors.New("read limit reached")
+
+// errDirectIOUnsupported is returned when a file opened for direct IO cannot
+// be written to with aligned buffers.
+var errDirectIOUnsupported = errors.New("direct IO is not supported")
+
+// tempFileSuffixRegex matches the suffix following ".tmp_" in names produced
+// by generateTempFileName, including those without a process ID.
+var tempFileSuffixRegex = regexp.MustCompile(`^([0-9]+_)?[0-9a-f]{16}$`)
+
+// generateTempFileName generates a unique temporary file name to avoid
+// collisions. The process ID is included so that names cannot collide between
+// processes writing to the same directory.
 func generateTempFileName(basePath string) (string, error) {
-	// Generate 8 random bytes and encode as hex (16 characters)
+	randomSuffix, err := generateRandomHex()
+	if err != nil {
+		return "", err
+	}
+	return basePath + ".tmp_" + strconv.Itoa(os.Getpid()) + "_" + randomSuffix, nil
+}
+
+// generateRandomHex returns 8 random bytes encoded as hex (16 characters).
+func generateRandomHex() (string, error) {
 	randomBytes := make([]byte, 8)
 	if _, err := rand.Read(randomBytes); err != nil {
 		return "", fmt.Errorf("failed to generate random bytes for temp file: %w", err)
 	}
-	randomSuffix := hex.EncodeToString(randomBytes)
-
-	return basePath + ".tmp_" + randomSuffix, nil
+	return hex.EncodeToString(randomBytes), nil
 }
 
 func (p *fileProcessor) Close(ctx context.Context) error {
+	if p.syncer != nil {
+		p.syncer.Close()
+	}
+	for _, proc := range p.conf.OnSuccess {
+		if err := proc.Close(ctx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,198 @@
+package io
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+)
+
+// errACLUnsupported is returned when the platform or filesystem does not
+// support POSIX ACLs.
+var errACLUnsupported = errors.New("POSIX ACLs are not supported for this file")
+
+// posixACLAccessXattr is the extended attribute that holds the access ACL of a
+// file on Linux.
+const posixACLAccessXattr = "system.posix_acl_access"
+
+const (
+	posixACLVersion     = 2
+	posixACLUndefinedID = 0xffffffff
+)
+
+// posixACLTag is a tag of the xattr encoding of an ACL along with the name
+// used for it by getfacl. Named entries carry the ID of a user or group, and
+// the entries for the owning user and group do not.
+type posixACLTag struct {
+	tag   uint16
+	name  string
+	named bool
+}
+
+var posixACLTags = []posixACLTag{
+	{tag: 0x01, name: "user"},
+	{tag: 0x02, name: "user", named: true},
+	{tag: 0x04, name: "group"},
+	{tag: 0x08, name: "group", named: true},
+	{tag: 0x10, name: "mask"},
+	{tag: 0x20, name: "other"},
+}
+
+// aclPermString renders ACL permission bits in the form used by getfacl, such
+// as "rw-".
+func aclPermString(perm uint16) string {
+	b := []byte("---")
+	for i, c := range "rwx" {
+		if perm&(4>>i) != 0 {
+			b[i] = byte(c)
+		}
+	}
+	return string(b)
+}
+
+// parseACLPermString parses permissions of the form "rw-" or "rw".
+func parseACLPermString(s string) (uint16, error) {
+	var perm uint16
+	for _, c := range s {
+		switch c {
+		case 'r':
+			perm |= 4
+		case 'w':
+			perm |= 2
+		case 'x':
+			perm |= 1
+		case '-':
+		default:
+			return 0, fmt.Errorf("invalid permissions '%s'", s)
+		}
+	}
+	return perm, nil
+}
+
+// decodePOSIXACL decodes the xattr encoding of an ACL into a list of entries,
+// each an object with a tag, permissions and, for named users and groups, an
+// ID.
+func decodePOSIXACL(data []byte) ([]any, error) {
+	if len(data) < 4 || (len(data)-4)%8 != 0 {
+		return nil, fmt.Errorf("invalid ACL of %d bytes", len(data))
+	}
+	if version := binary.LittleEndian.Uint32(data); version != posixACLVersion {
+		return nil, fmt.Errorf("unsupported ACL version %d", version)
+	}
+
+	var entries []any
+	for data = data[4:]; len(data) > 0; data = data[8:] {
+		tag := binary.LittleEndian.Uint16(data)
+		i := slices.IndexFunc(posixACLTags, func(t posixACLTag) bool {
+			return t.tag == tag
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("unrecognised ACL tag %#x", tag)
+		}
+
+		entry := map[string]any{
+			"tag":   posixACLTags[i].name,
+			"perms": aclPermString(binary.LittleEndian.Uint16(data[2:])),
+		}
+		if posixACLTags[i].named {
+			entry["id"] = int64(binary.LittleEndian.Uint32(data[4:]))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// encodePOSIXACL encodes a list of entries, in the form returned by
+// decodePOSIXACL, into the xattr encoding of an ACL. Entries are sorted as
+// required by the kernel.
+func encodePOSIXACL(entries []any) ([]byte, error) {
+	type rawEntry struct {
+		tag  uint16
+		perm uint16
+		id   uint32
+	}
+
+	raw := make([]rawEntry, 0, len(entries))
+	for _, e := range entries {
+		obj, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected ACL entry to be an object, got %T", e)
+		}
+		name, _ := obj["tag"].(string)
+		permStr, _ := obj["perms"].(string)
+		perm, err := parseACLPermString(permStr)
+		if err != nil {
+			return nil, err
+		}
+
+		id, named := obj["id"]
+		entry := rawEntry{perm: perm, id: posixACLUndefinedID}
+		i := slices.IndexFunc(posixACLTags, func(t posixACLTag) bool {
+			return t.name == name && t.named == named
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("invalid ACL entry with tag '%v'", obj["tag"])
+		}
+		entry.tag = posixACLTags[i].tag
+		if named {
+			n, err := aclEntryID(id)
+			if err != nil {
+				return nil, err
+			}
+			entry.id = n
+		}
+		raw = append(raw, entry)
+	}
+	slices.SortFunc(raw, func(a, b rawEntry) int {
+		if a.tag != b.tag {
+			return cmp.Compare(a.tag, b.tag)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+
+	data := binary.LittleEndian.AppendUint32(nil, posixACLVersion)
+	for _, e := range raw {
+		data = binary.LittleEndian.AppendUint16(data, e.tag)
+		data = binary.LittleEndian.AppendUint16(data, e.perm)
+		data = binary.LittleEndian.AppendUint32(data, e.id)
+	}
+	return data, nil
+}
+
+// aclEntryID returns the ID of a named ACL entry, which may be a number of any
+// type produced by parsing JSON or Bloblang.
+func aclEntryID(v any) (uint32, error) {
+	var n int64
+	switch t := v.(type) {
+	case int64:
+		n = t
+	case int:
+		n = int64(t)
+	case uint64:
+		n = int64(t)
+	case float64:
+		if t != float64(int64(t)) {
+			return 0, fmt.Errorf("invalid ACL entry id %v", v)
+		}
+		n = int64(t)
+	default:
+		return 0, fmt.Errorf("invalid ACL entry id %v", v)
+	}
+	if n < 0 || n >= posixACLUndefinedID {
+		return 0, fmt.Errorf("invalid ACL entry id %v", v)
+	}
+	return uint32(n), nil
+}
+
+// minimalPOSIXACL returns the entries of the ACL equivalent to the permission
+// bits of mode, which is the ACL of files without an extended ACL.
+func minimalPOSIXACL(mode fs.FileMode) []any {
+	perm := uint16(mode.Perm())
+	return []any{
+		map[string]any{"tag": "user", "perms": aclPermString(perm >> 6 & 7)},
+		map[string]any{"tag": "group", "perms": aclPermString(perm >> 3 & 7)},
+		map[string]any{"tag": "other", "perms": aclPermString(perm & 7)},
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package io
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// getFileACL returns the xattr encoding of the access ACL of the file at path,
+// or nil if the file has no extended ACL. An error matching errACLUnsupported
+// is returned when the filesystem does not support ACLs.
+func getFileACL(path string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, posixACLAccessXattr, nil)
+		if err != nil {
+			return nil, aclError(err)
+		}
+		data := make([]byte, size)
+		n, err := unix.Getxattr(path, posixACLAccessXattr, data)
+		if errors.Is(err, unix.ERANGE) {
+			// The ACL grew between the calls
+			continue
+		}
+		if err != nil {
+			return nil, aclError(err)
+		}
+		return data[:n], nil
+	}
+}
+
+// setFileACL replaces the access ACL of the file at path with the xattr
+// encoded ACL data.
+func setFileACL(path string, data []byte) error {
+	return aclError(unix.Setxattr(path, posixACLAccessXattr, data, 0))
+}
+
+func aclError(err error) error {
+	if errors.Is(err, unix.ENODATA) {
+		return nil
+	}
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return errACLUnsupported
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package io
+
+// getFileACL always returns errACLUnsupported on platforms without POSIX ACLs.
+func getFileACL(path string) ([]byte, error) {
+	return nil, errACLUnsupported
+}
+
+// setFileACL always returns errACLUnsupported on platforms without POSIX ACLs.
+func setFileACL(path string, data []byte) error {
+	return errACLUnsupported
+}
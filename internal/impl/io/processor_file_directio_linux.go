@@ -0,0 +1,75 @@
+//go:build linux
+
+package io
+
+import (
+	"errors"
+	"io"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// directIOFlag is the open flag used to bypass the page cache.
+	directIOFlag = unix.O_DIRECT
+
+	// directIOAlignment is the block size that buffers, offsets and lengths
+	// must be aligned to when writing with O_DIRECT.
+	directIOAlignment = 4096
+
+	// directIOChunkSize is the size of the aligned buffer content is copied
+	// through when writing, and must be a multiple of directIOAlignment.
+	directIOChunkSize = 256 * directIOAlignment
+)
+
+// writeDirect writes content to w, which must be an *os.File (or equivalent
+// exposing Fd) opened with O_DIRECT. Whole blocks are written from an aligned
+// buffer, after which O_DIRECT is cleared in order to write any unaligned tail.
+func writeDirect(w io.Writer, content []byte) error {
+	fder, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return errDirectIOUnsupported
+	}
+
+	buf := alignedBuffer(directIOChunkSize)
+	whole := len(content) - len(content)%directIOAlignment
+	for off := 0; off < whole; {
+		n := copy(buf, content[off:whole])
+		if err := writeFull(w, buf[:n]); err != nil {
+			return err
+		}
+		off += n
+	}
+
+	if whole == len(content) {
+		return nil
+	}
+
+	fd := int(fder.Fd())
+	flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFL, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_SETFL, flags&^unix.O_DIRECT); err != nil {
+		return err
+	}
+	return writeFull(w, content[whole:])
+}
+
+// isDirectIOUnsupported returns true if err indicates that the filesystem does
+// not support O_DIRECT.
+func isDirectIOUnsupported(err error) bool {
+	return errors.Is(err, errDirectIOUnsupported) || errors.Is(err, unix.EINVAL)
+}
+
+// alignedBuffer returns a buffer of size bytes that begins at an address
+// aligned to directIOAlignment.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directIOAlignment)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (directIOAlignment - 1)); rem != 0 {
+		offset = directIOAlignment - rem
+	}
+	return buf[offset : offset+size]
+}
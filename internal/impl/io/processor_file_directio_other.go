@@ -0,0 +1,19 @@
+//go:build !linux
+
+package io
+
+import (
+	"errors"
+	"io"
+)
+
+// directIOFlag is zero on platforms where O_DIRECT is not supported.
+const directIOFlag = 0
+
+func writeDirect(w io.Writer, content []byte) error {
+	return errDirectIOUnsupported
+}
+
+func isDirectIOUnsupported(err error) bool {
+	return errors.Is(err, errDirectIOUnsupported)
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package io
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// isFallbackWriteError returns true if err indicates that a write failed
+// because the destination is full or cannot be written to.
+func isFallbackWriteError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) ||
+		errors.Is(err, syscall.EDQUOT) ||
+		errors.Is(err, syscall.EROFS) ||
+		errors.Is(err, fs.ErrPermission)
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package io
+
+import (
+	"errors"
+	"io/fs"
+
+	"golang.org/x/sys/windows"
+)
+
+// isFallbackWriteError returns true if err indicates that a write failed
+// because the destination is full or cannot be written to.
+func isFallbackWriteError(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) ||
+		errors.Is(err, windows.ERROR_HANDLE_DISK_FULL) ||
+		errors.Is(err, windows.ERROR_WRITE_PROTECT) ||
+		errors.Is(err, fs.ErrPermission)
+}
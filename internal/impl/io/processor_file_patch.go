@@ -0,0 +1,391 @@
+package io
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var unifiedHunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// unifiedDiffHunk is a single hunk of a unified diff. Lines retain their
+// prefix character (' ', '-' or '+') followed by their content including the
+// line terminator, which is absent when the line is marked with "\ No newline
+// at end of file".
+type unifiedDiffHunk struct {
+	oldStart int
+	oldLines int
+	lines    []string
+}
+
+// oldAndNew returns the lines that the hunk expects to find and the lines that
+// replace them.
+func (h unifiedDiffHunk) oldAndNew() (oldLines, newLines []string) {
+	for _, l := range h.lines {
+		switch l[0] {
+		case ' ':
+			oldLines = append(oldLines, l[1:])
+			newLines = append(newLines, l[1:])
+		case '-':
+			oldLines = append(oldLines, l[1:])
+		case '+':
+			newLines = append(newLines, l[1:])
+		}
+	}
+	return
+}
+
+// countLines returns the number of lines in b, including a final line without
+// a "\n" terminator.
+func countLines(b []byte) int {
+	lines := bytes.Count(b, []byte("\n"))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+// splitLinesKeepEnds splits b into lines that retain their "\n" terminators.
+func splitLinesKeepEnds(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			lines = append(lines, string(b))
+			break
+		}
+		lines = append(lines, string(b[:i+1]))
+		b = b[i+1:]
+	}
+	return lines
+}
+
+// parseUnifiedDiff parses the hunks of a unified diff that targets a single
+// file. File headers and any other lines outside of hunks are ignored.
+func parseUnifiedDiff(diff []byte) ([]unifiedDiffHunk, error) {
+	lines := splitLinesKeepEnds(diff)
+
+	var hunks []unifiedDiffHunk
+	var fileHeaders int
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if strings.HasPrefix(line, "--- ") {
+			if fileHeaders++; fileHeaders > 1 {
+				return nil, errors.New("patches that modify multiple files are not supported")
+			}
+			continue
+		}
+
+		m := unifiedHunkHeaderRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		hunk := unifiedDiffHunk{oldLines: 1}
+		hunk.oldStart, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			hunk.oldLines, _ = strconv.Atoi(m[2])
+		}
+		newLines := 1
+		if m[4] != "" {
+			newLines, _ = strconv.Atoi(m[4])
+		}
+
+		oldRemaining, newRemaining := hunk.oldLines, newLines
+		for oldRemaining > 0 || newRemaining > 0 {
+			if i++; i >= len(lines) {
+				return nil, fmt.Errorf("hunk at line %d is truncated", hunk.oldStart)
+			}
+			l := lines[i]
+			if l == "\n" || l == "\r\n" {
+				// Some tools strip the trailing space of empty context lines
+				l = " " + l
+			}
+			switch l[0] {
+			case ' ':
+				oldRemaining--
+				newRemaining--
+			case '-':
+				oldRemaining--
+			case '+':
+				newRemaining--
+			default:
+				return nil, fmt.Errorf("unexpected line in hunk at line %d: %q", hunk.oldStart, strings.TrimRight(l, "\r\n"))
+			}
+			if oldRemaining < 0 || newRemaining < 0 {
+				return nil, fmt.Errorf("hunk at line %d has more lines than its header declares", hunk.oldStart)
+			}
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], `\`) {
+				l = strings.TrimSuffix(l, "\n")
+				i++
+			}
+			hunk.lines = append(hunk.lines, l)
+		}
+		hunks = append(hunks, hunk)
+	}
+	if len(hunks) == 0 {
+		return nil, errors.New("patch does not contain any hunks")
+	}
+	return hunks, nil
+}
+
+// applyUnifiedDiff applies hunks to content. Hunks whose lines are not found
+// at their stated position are searched for nearby, and when not found at all
+// are counted as failed, in which case applying stops with an error unless
+// skipFailed is true.
+func applyUnifiedDiff(content []byte, hunks []unifiedDiffHunk, skipFailed bool) (result []byte, applied, failed int, err error) {
+	lines := splitLinesKeepEnds(content)
+
+	var out bytes.Buffer
+	pos := 0
+	for _, hunk := range hunks {
+		oldLines, newLines := hunk.oldAndNew()
+
+		expected := hunk.oldStart - 1
+		if hunk.oldLines == 0 {
+			// Pure insertions are positioned after the stated line
+			expected = hunk.oldStart
+		}
+
+		idx := findLines(lines, oldLines, pos, expected)
+		if idx < 0 {
+			failed++
+			if !skipFailed {
+				return nil, applied, failed, fmt.Errorf("hunk at line %d does not apply", hunk.oldStart)
+			}
+			continue
+		}
+
+		for _, l := range lines[pos:idx] {
+			out.WriteString(l)
+		}
+		for _, l := range newLines {
+			out.WriteString(l)
+		}
+		pos = idx + len(oldLines)
+		applied++
+	}
+	for _, l := range lines[pos:] {
+		out.WriteString(l)
+	}
+	return out.Bytes(), applied, failed, nil
+}
+
+// findLines returns the index of the occurrence of want within lines, at or
+// after from, that is closest to expected, or -1 if there is none.
+func findLines(lines, want []string, from, expected int) int {
+	last := len(lines) - len(want)
+	matches := func(idx int) bool {
+		if idx < from || idx > last {
+			return false
+		}
+		for i, w := range want {
+			if lines[idx+i] != w {
+				return false
+			}
+		}
+		return true
+	}
+	for offset := 0; expected-offset >= from || expected+offset <= last; offset++ {
+		if matches(expected + offset) {
+			return expected + offset
+		}
+		if offset > 0 && matches(expected-offset) {
+			return expected - offset
+		}
+	}
+	return -1
+}
+
+// diffLine is a line of an edit script, prefixed in the same way as the lines
+// of a unifiedDiffHunk.
+type diffLine struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the shortest edit script that transforms a into b,
+// computed with the linear space variant of the Myers difference algorithm.
+func diffLines(a, b []string) []diffLine {
+	d := &lineDiff{a: a, b: b}
+	d.compare(0, len(a), 0, len(b))
+	return d.script
+}
+
+// lineDiff accumulates the edit script between two sets of lines.
+type lineDiff struct {
+	a, b   []string
+	script []diffLine
+}
+
+// compare appends the edit script that transforms a[aLo:aHi] into b[bLo:bHi],
+// dividing the problem around the middle snake of a shortest edit path such
+// that memory use is linear in the number of lines.
+func (d *lineDiff) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.script = append(d.script, diffLine{op: ' ', line: d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for _, line := range d.b[bLo:bHi] {
+			d.script = append(d.script, diffLine{op: '+', line: line})
+		}
+	case bLo == bHi:
+		for _, line := range d.a[aLo:aHi] {
+			d.script = append(d.script, diffLine{op: '-', line: line})
+		}
+	default:
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for _, line := range d.a[x:u] {
+			d.script = append(d.script, diffLine{op: ' ', line: line})
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for _, line := range d.a[aHi : aHi+suffix] {
+		d.script = append(d.script, diffLine{op: ' ', line: line})
+	}
+}
+
+// middleSnake searches for a shortest edit path from both ends of a[aLo:aHi]
+// and b[bLo:bHi] at once, returning the diagonal run of matching lines from
+// (x, y) to (u, v) where the two searches meet. The ranges must differ at both
+// their first and last lines.
+func (d *lineDiff) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+
+	// The furthest x reached on each diagonal k = x - y, where backward
+	// positions are measured from the ends of the ranges.
+	off := maxD + 1
+	forward := make([]int, 2*off+1)
+	backward := make([]int, 2*off+1)
+
+	for e := 0; e <= maxD; e++ {
+		for k := -e; k <= e; k += 2 {
+			if k == -e || (k != e && forward[off+k-1] < forward[off+k+1]) {
+				x = forward[off+k+1]
+			} else {
+				x = forward[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && d.a[aLo+u] == d.b[bLo+v] {
+				u++
+				v++
+			}
+			forward[off+k] = u
+			if rk := delta - k; odd && rk >= -(e-1) && rk <= e-1 && u+backward[off+rk] >= n {
+				return aLo + x, bLo + y, aLo + u, bLo + v
+			}
+		}
+		for rk := -e; rk <= e; rk += 2 {
+			if rk == -e || (rk != e && backward[off+rk-1] < backward[off+rk+1]) {
+				x = backward[off+rk+1]
+			} else {
+				x = backward[off+rk-1] + 1
+			}
+			y = x - rk
+			u, v = x, y
+			for u < n && v < m && d.a[aHi-u-1] == d.b[bHi-v-1] {
+				u++
+				v++
+			}
+			backward[off+rk] = u
+			if k := delta - rk; !odd && k >= -e && k <= e && forward[off+k]+u >= n {
+				return aHi - u, bHi - v, aHi - x, bHi - y
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+// unifiedDiff returns a unified diff, with contextLines lines of context
+// around each change, that transforms oldContent into newContent and can be
+// applied with applyUnifiedDiff. The diff is empty when the contents are
+// identical.
+func unifiedDiff(oldName, newName string, oldContent, newContent []byte, contextLines int) (diff []byte, added, removed int) {
+	script := diffLines(splitLinesKeepEnds(oldContent), splitLinesKeepEnds(newContent))
+
+	var changes []int
+	for i, l := range script {
+		if l.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil, 0, 0
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	oldPos, newPos, scriptPos := 0, 0, 0
+	for c := 0; c < len(changes); {
+		// Group changes separated by no more than twice the context
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*contextLines+1 {
+			last++
+		}
+		start := max(changes[c]-contextLines, 0)
+		end := min(changes[last]+contextLines+1, len(script))
+
+		for ; scriptPos < start; scriptPos++ {
+			oldPos++
+			newPos++
+		}
+
+		var oldLines, newLines int
+		for _, l := range script[start:end] {
+			if l.op != '+' {
+				oldLines++
+			}
+			if l.op != '-' {
+				newLines++
+			}
+		}
+		oldStart, newStart := oldPos, newPos
+		if oldLines > 0 {
+			oldStart++
+		}
+		if newLines > 0 {
+			newStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLines, newStart, newLines)
+
+		for _, l := range script[start:end] {
+			out.WriteByte(l.op)
+			out.WriteString(l.line)
+			if !strings.HasSuffix(l.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+			switch l.op {
+			case ' ':
+				oldPos++
+				newPos++
+			case '-':
+				oldPos++
+				removed++
+			case '+':
+				newPos++
+				added++
+			}
+		}
+		scriptPos = end
+		c = last + 1
+	}
+	return out.Bytes(), added, removed
+}
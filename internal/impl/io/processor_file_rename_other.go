@@ -0,0 +1,14 @@
+//go:build !windows
+
+package io
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTransientRenameError returns true if err indicates that a rename failed
+// because the destination was momentarily busy.
+func isTransientRenameError(err error) bool {
+	return errors.Is(err, syscall.ETXTBSY) || errors.Is(err, syscall.EBUSY)
+}
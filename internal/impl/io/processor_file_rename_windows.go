@@ -0,0 +1,15 @@
+//go:build windows
+
+package io
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isTransientRenameError returns true if err indicates that a rename failed
+// because the destination was momentarily held open by another process.
+func isTransientRenameError(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
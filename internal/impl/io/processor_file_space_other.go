@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package io
+
+import (
+	"errors"
+)
+
+// spaceCheckSupported is false on platforms where diskSpaceAvailable is not
+// implemented.
+const spaceCheckSupported = false
+
+func diskSpaceAvailable(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin || freebsd
+
+package io
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// spaceCheckSupported is true on platforms where diskSpaceAvailable is
+// implemented.
+const spaceCheckSupported = true
+
+// diskSpaceAvailable returns the number of bytes available to unprivileged
+// users on the filesystem containing dir.
+func diskSpaceAvailable(dir string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
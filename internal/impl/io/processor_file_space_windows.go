@@ -0,0 +1,25 @@
+//go:build windows
+
+package io
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// spaceCheckSupported is true on platforms where diskSpaceAvailable is
+// implemented.
+const spaceCheckSupported = true
+
+// diskSpaceAvailable returns the number of bytes available to the calling user
+// on the volume containing dir.
+func diskSpaceAvailable(dir string) (uint64, error) {
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(dirPtr, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package io
+
+import (
+	"io/fs"
+)
+
+// fileIdentity returns false as inode and device numbers are not available on
+// this platform.
+func fileIdentity(info fs.FileInfo) (inode, device uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package io
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentity returns the inode and device numbers of the file described by
+// info, if they are available.
+func fileIdentity(info fs.FileInfo) (inode, device uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Ino), uint64(stat.Dev), true
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package io
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchangeFiles atomically exchanges the files at oldpath and newpath using
+// renameat2 with RENAME_EXCHANGE. An error matching errExchangeUnsupported is
+// returned when the kernel or filesystem does not support the exchange.
+func exchangeFiles(oldpath, newpath string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, oldpath, unix.AT_FDCWD, newpath, unix.RENAME_EXCHANGE)
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP) {
+		return errExchangeUnsupported
+	}
+	return err
+}
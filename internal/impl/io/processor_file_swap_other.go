@@ -0,0 +1,9 @@
+//go:build !linux
+
+package io
+
+// exchangeFiles always returns errExchangeUnsupported on platforms without an
+// atomic exchange.
+func exchangeFiles(oldpath, newpath string) error {
+	return errExchangeUnsupported
+}
@@ -0,0 +1,112 @@
+package io
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/warpstreamlabs/bento/internal/component"
+)
+
+// fileSyncBatch is a set of written paths that are flushed to stable storage
+// together.
+type fileSyncBatch struct {
+	paths []string
+	errs  map[string]error
+	done  chan struct{}
+}
+
+func newFileSyncBatch() *fileSyncBatch {
+	return &fileSyncBatch{done: make(chan struct{})}
+}
+
+// fileSyncer flushes written paths to stable storage on an interval, such that
+// the cost of each flush is shared by all of the writes made within it.
+type fileSyncer struct {
+	interval time.Duration
+	sync     func(paths []string) map[string]error
+
+	mut     sync.Mutex
+	current *fileSyncBatch
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+	doneChan  chan struct{}
+}
+
+func newFileSyncer(interval time.Duration, sync func(paths []string) map[string]error) *fileSyncer {
+	s := &fileSyncer{
+		interval:  interval,
+		sync:      sync,
+		current:   newFileSyncBatch(),
+		closeChan: make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *fileSyncer) loop() {
+	defer close(s.doneChan)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.closeChan:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush syncs the paths of the current batch and releases those waiting on it.
+func (s *fileSyncer) flush() {
+	s.mut.Lock()
+	batch := s.current
+	s.current = newFileSyncBatch()
+	s.mut.Unlock()
+
+	if len(batch.paths) > 0 {
+		batch.errs = s.sync(batch.paths)
+	}
+	close(batch.done)
+}
+
+// Sync adds paths to the current batch and blocks until it has been flushed,
+// returning the first error encountered flushing any of them.
+func (s *fileSyncer) Sync(ctx context.Context, paths ...string) error {
+	s.mut.Lock()
+	batch := s.current
+	batch.paths = append(batch.paths, paths...)
+	s.mut.Unlock()
+
+	select {
+	case <-batch.done:
+	case <-s.doneChan:
+		// Batches added to after the final flush are never synced
+		select {
+		case <-batch.done:
+		default:
+			return component.ErrTypeClosed
+		}
+	case <-ctx.Done():
+		return component.ErrTimeout
+	}
+	for _, path := range paths {
+		if err := batch.errs[path]; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes any pending paths and stops the syncer.
+func (s *fileSyncer) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+	})
+	<-s.doneChan
+}
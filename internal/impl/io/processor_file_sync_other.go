@@ -0,0 +1,13 @@
+//go:build !windows
+
+package io
+
+import "os"
+
+// syncOpenFlag is the flag used to open a written file in order to flush it.
+const syncOpenFlag = os.O_RDONLY
+
+// syncDirectories is whether the directories containing written files are
+// flushed so that their entries, such as those created by a rename, are
+// durable.
+const syncDirectories = true
@@ -0,0 +1,14 @@
+//go:build windows
+
+package io
+
+import "os"
+
+// syncOpenFlag is the flag used to open a written file in order to flush it,
+// which on Windows requires write access.
+const syncOpenFlag = os.O_RDWR
+
+// syncDirectories is whether the directories containing written files are
+// flushed. Windows does not support flushing directories, and persists the
+// metadata of a rename with the file itself.
+const syncDirectories = false
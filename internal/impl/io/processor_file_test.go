@@ -1,12 +1,35 @@
 package io
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/warpstreamlabs/bento/internal/component"
+	"github.com/warpstreamlabs/bento/internal/filepath/ifs"
+	"github.com/warpstreamlabs/bento/internal/manager/mock"
+	"github.com/warpstreamlabs/bento/internal/message"
+	"github.com/warpstreamlabs/bento/public/bloblang"
 	"github.com/warpstreamlabs/bento/public/service"
 )
 
@@ -140,6 +163,78 @@ func TestFileProcessorDelete(t *testing.T) {
 	}
 }
 
+func TestFileProcessorWriteModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permission bits are not supported on Windows")
+	}
+
+	tempDir := t.TempDir()
+	testDir := filepath.Join(tempDir, "private")
+	testFile := filepath.Join(testDir, "output.txt")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"file_mode": "0600",
+		"dir_mode": "0700"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("secret"))); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	fileInfo, err := os.Stat(testFile)
+	if err != nil {
+		t.Fatal("Failed to stat file:", err)
+	}
+	if mode := fileInfo.Mode().Perm(); mode != 0o600 {
+		t.Errorf("Expected file mode 0600, got %04o", mode)
+	}
+	dirInfo, err := os.Stat(testDir)
+	if err != nil {
+		t.Fatal("Failed to stat directory:", err)
+	}
+	if mode := dirInfo.Mode().Perm(); mode != 0o700 {
+		t.Errorf("Expected directory mode 0700, got %04o", mode)
+	}
+}
+
+func TestFileProcessorModeLintRule(t *testing.T) {
+	for _, mode := range []string{"rw-r--r--", "0800", "06440", ""} {
+		t.Run(mode, func(t *testing.T) {
+			err := service.NewStreamBuilder().SetYAML(`
+pipeline:
+  processors:
+    - file:
+        operation: write
+        path: /tmp/out.txt
+        file_mode: "` + mode + `"
+output:
+  drop: {}
+`)
+			if err == nil || !strings.Contains(err.Error(), "'file_mode' must be an octal string") {
+				t.Errorf("Expected file_mode '%s' to be rejected by the linter, got: %v", mode, err)
+			}
+		})
+	}
+
+	if err := service.NewStreamBuilder().SetYAML(`
+pipeline:
+  processors:
+    - file:
+        operation: write
+        path: /tmp/out.txt
+        file_mode: "640"
+        dir_mode: "0750"
+output:
+  drop: {}
+`); err != nil {
+		t.Errorf("Expected octal modes to be accepted, got: %v", err)
+	}
+}
+
 func TestFileProcessorMove(t *testing.T) {
 	tempDir := t.TempDir()
 	srcFile := filepath.Join(tempDir, "source.txt")
@@ -188,6 +283,147 @@ func TestFileProcessorMove(t *testing.T) {
 	}
 }
 
+func TestFileProcessorCopy(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFile := filepath.Join(tempDir, "source.txt")
+	destFile := filepath.Join(tempDir, "backup", "destination.txt")
+	testContent := "Copy me"
+
+	if err := os.WriteFile(srcFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal("Failed to create source file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "copy",
+		"path": "` + srcFile + `",
+		"destination_path": "` + destFile + `"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte("test message")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+	if v, _ := result[0].MetaGet("file_dest_path"); v != destFile {
+		t.Errorf("Expected file_dest_path '%s', got '%s'", destFile, v)
+	}
+
+	srcContent, err := os.ReadFile(srcFile)
+	if err != nil {
+		t.Fatal("Expected source file to be preserved:", err)
+	}
+	destContent, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatal("Failed to read destination file:", err)
+	}
+	if string(srcContent) != testContent || string(destContent) != testContent {
+		t.Errorf("Expected source and destination content '%s', got '%s' and '%s'", testContent, srcContent, destContent)
+	}
+
+	tempFiles, _ := filepath.Glob(filepath.Join(tempDir, "backup", "*.tmp_*"))
+	if len(tempFiles) > 0 {
+		t.Errorf("Found leftover temporary files: %v", tempFiles)
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "copy",
+		"path": "` + srcFile + `"
+	}`); err == nil {
+		t.Error("Expected copy without a destination_path to be rejected")
+	}
+}
+
+func TestFileProcessorList(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, file := range []string{"b.txt", "a/one.txt", "a/nested/two.txt", "c/three.txt"} {
+		path := filepath.Join(tempDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal("Failed to create directory:", err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+			t.Fatal("Failed to create file:", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		recursive bool
+		expected  []string
+	}{
+		{
+			name:     "flat",
+			expected: []string{"a", "b.txt", "c"},
+		},
+		{
+			name:      "recursive",
+			recursive: true,
+			expected:  []string{"a", "a/nested", "a/nested/two.txt", "a/one.txt", "b.txt", "c", "c/three.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "list",
+				"path": "` + tempDir + `",
+				"recursive": ` + strconv.FormatBool(test.recursive) + `
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			var paths []string
+			for _, msg := range result {
+				content, err := msg.AsBytes()
+				if err != nil {
+					t.Fatal("Failed to get message content:", err)
+				}
+				if v, _ := msg.MetaGet("file_path"); v != string(content) {
+					t.Errorf("Expected file_path '%s' to match content, got '%s'", content, v)
+				}
+				rel, err := filepath.Rel(tempDir, string(content))
+				if err != nil {
+					t.Fatal("Failed to get relative path:", err)
+				}
+				rel = filepath.ToSlash(rel)
+				paths = append(paths, rel)
+
+				isDir, _ := msg.MetaGetMut("file_is_dir")
+				if wantDir := !strings.HasSuffix(rel, ".txt"); isDir != wantDir {
+					t.Errorf("Expected file_is_dir %v for '%s', got %v", wantDir, rel, isDir)
+				}
+				if !strings.HasSuffix(rel, ".txt") {
+					continue
+				}
+				if size, _ := msg.MetaGetMut("file_size"); size != int64(len(rel)) {
+					t.Errorf("Expected file_size %d for '%s', got %v", len(rel), rel, size)
+				}
+			}
+			if !reflect.DeepEqual(paths, test.expected) {
+				t.Errorf("Expected paths %v, got %v", test.expected, paths)
+			}
+		})
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "stat",
+		"path": "` + tempDir + `",
+		"recursive": true
+	}`); err == nil {
+		t.Error("Expected recursive to be rejected for operations other than list")
+	}
+}
+
 func TestFileProcessorStat(t *testing.T) {
 	tempDir := t.TempDir()
 	testFile := filepath.Join(tempDir, "test.txt")
@@ -534,6 +770,143 @@ func newFileProcessorFromConfig(conf string) (*fileProcessor, error) {
 	return fileProcessorFromParsed(parsed, service.MockResources())
 }
 
+// fileProcessorTestFS wraps an ifs.FS, allowing individual calls to be
+// overridden in order to simulate filesystem failures.
+type fileProcessorTestFS struct {
+	ifs.FS
+	open     func(name string) (fs.File, error)
+	openFile func(name string, flag int, perm fs.FileMode) (fs.File, error)
+	remove   func(name string) error
+}
+
+func (f *fileProcessorTestFS) Open(name string) (fs.File, error) {
+	if f.open != nil {
+		return f.open(name)
+	}
+	return f.FS.Open(name)
+}
+
+func (f *fileProcessorTestFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if f.openFile != nil {
+		return f.openFile(name, flag, perm)
+	}
+	return f.FS.OpenFile(name, flag, perm)
+}
+
+func (f *fileProcessorTestFS) Remove(name string) error {
+	if f.remove != nil {
+		return f.remove(name)
+	}
+	return f.FS.Remove(name)
+}
+
+func newFileProcessorFromConfigWithFS(conf string, filesystem ifs.FS) (*fileProcessor, error) {
+	return newFileProcessorFromConfigWithMock(conf, func(m *mock.Manager) {
+		m.CustomFS = filesystem
+	})
+}
+
+func newFileProcessorFromConfigWithMock(conf string, fn func(m *mock.Manager)) (*fileProcessor, error) {
+	parsed, err := fileProcessorSpec().ParseYAML(conf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileProcessorFromParsed(parsed, service.MockResources(fn))
+}
+
+// rootedTestFS is a virtual filesystem that resolves every path beneath root,
+// such that operations which bypass it touch the wrong files.
+type rootedTestFS struct {
+	root    string
+	renames int
+}
+
+func (r *rootedTestFS) path(name string) string {
+	return filepath.Join(r.root, name)
+}
+
+func (r *rootedTestFS) Open(name string) (fs.File, error) {
+	return os.Open(r.path(name))
+}
+
+func (r *rootedTestFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	return os.OpenFile(r.path(name), flag, perm)
+}
+
+func (r *rootedTestFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(r.path(name))
+}
+
+func (r *rootedTestFS) Remove(name string) error {
+	return os.Remove(r.path(name))
+}
+
+func (r *rootedTestFS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(r.path(path), perm)
+}
+
+func (r *rootedTestFS) Rename(oldpath, newpath string) error {
+	r.renames++
+	return os.Rename(r.path(oldpath), r.path(newpath))
+}
+
+func (r *rootedTestFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(r.path(name))
+}
+
+func (r *rootedTestFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, r.path(newname))
+}
+
+func TestFileProcessorRenamesThroughResourceFS(t *testing.T) {
+	root := t.TempDir()
+	virtualFS := &rootedTestFS{root: root}
+
+	writeProc, err := newFileProcessorFromConfigWithFS(`{
+		"operation": "write",
+		"path": "/data/out.txt"
+	}`, virtualFS)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := writeProc.Process(context.Background(), service.NewMessage([]byte("virtual"))); err != nil {
+		t.Fatal("Write failed:", err)
+	}
+	if virtualFS.renames != 1 {
+		t.Errorf("Expected the write to rename through the filesystem once, got %d", virtualFS.renames)
+	}
+
+	renameProc, err := newFileProcessorFromConfigWithFS(`{
+		"operation": "rename",
+		"path": "/data/out.txt",
+		"destination_path": "/data/renamed.txt"
+	}`, virtualFS)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := renameProc.Process(context.Background(), service.NewMessage(nil)); err != nil {
+		t.Fatal("Rename failed:", err)
+	}
+	if virtualFS.renames != 2 {
+		t.Errorf("Expected the rename to go through the filesystem, got %d renames", virtualFS.renames)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "data", "out.txt")); !os.IsNotExist(err) {
+		t.Error("Expected the written file to have been renamed")
+	}
+	content, err := os.ReadFile(filepath.Join(root, "data", "renamed.txt"))
+	if err != nil {
+		t.Fatal("Expected the renamed file within the virtual filesystem:", err)
+	}
+	if string(content) != "virtual" {
+		t.Errorf("Expected content 'virtual', got '%s'", content)
+	}
+	if tempFiles, _ := filepath.Glob(filepath.Join(root, "data", "*.tmp_*")); len(tempFiles) > 0 {
+		t.Errorf("Found leftover temporary files: %v", tempFiles)
+	}
+}
+
 func TestFileProcessorWithScanner(t *testing.T) {
 	tempDir := t.TempDir()
 	testFile := filepath.Join(tempDir, "test.txt")
@@ -588,3 +961,4173 @@ scanner:
 		}
 	}
 }
+
+func TestFileProcessorMktemp(t *testing.T) {
+	tempDir := t.TempDir()
+	testContent := "Staged content"
+
+	conf := `{
+		"operation": "mktemp",
+		"path": "` + tempDir + `",
+		"pattern": "staged-*.txt"
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	seen := map[string]struct{}{}
+	for i := 0; i < 3; i++ {
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte(testContent)))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+
+		if len(result) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(result))
+		}
+
+		filePath, exists := result[0].MetaGet("file_path")
+		if !exists {
+			t.Fatal("Expected file_path metadata to be set")
+		}
+		if _, dup := seen[filePath]; dup {
+			t.Fatalf("Expected a unique path, got '%s' twice", filePath)
+		}
+		seen[filePath] = struct{}{}
+
+		if dir := filepath.Dir(filePath); dir != tempDir {
+			t.Errorf("Expected file in '%s', got '%s'", tempDir, dir)
+		}
+		name := filepath.Base(filePath)
+		if !strings.HasPrefix(name, "staged-") || !strings.HasSuffix(name, ".txt") {
+			t.Errorf("Expected file name to match pattern, got '%s'", name)
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal("Failed to read created file:", err)
+		}
+		if string(content) != testContent {
+			t.Errorf("Expected file content '%s', got '%s'", testContent, string(content))
+		}
+	}
+}
+
+type fileProcessorChunkedWriter struct {
+	written   []byte
+	chunkSize int
+}
+
+func (w *fileProcessorChunkedWriter) Write(p []byte) (int, error) {
+	n := min(len(p), w.chunkSize)
+	w.written = append(w.written, p[:n]...)
+	return n, nil
+}
+
+func TestFileProcessorWriteFullShortWrites(t *testing.T) {
+	content := []byte("This content is written a few bytes at a time")
+
+	w := &fileProcessorChunkedWriter{chunkSize: 3}
+	if err := writeFull(w, content); err != nil {
+		t.Fatal("Expected short writes to be retried, got:", err)
+	}
+	if string(w.written) != string(content) {
+		t.Errorf("Expected written content '%s', got '%s'", content, w.written)
+	}
+
+	stuck := &fileProcessorChunkedWriter{chunkSize: 0}
+	if err := writeFull(stuck, content); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Expected io.ErrShortWrite for a writer making no progress, got: %v", err)
+	}
+}
+
+func TestFileProcessorFilter(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFile := filepath.Join(tempDir, "source.log")
+	destFile := filepath.Join(tempDir, "filtered", "errors.log")
+
+	if err := os.WriteFile(srcFile, []byte("INFO started\nERROR failed\r\nINFO running\nERROR again"), 0o644); err != nil {
+		t.Fatal("Failed to create source file:", err)
+	}
+
+	conf := `
+operation: filter
+path: ` + srcFile + `
+destination_path: ` + destFile + `
+predicate: 'content().string().has_prefix(@level)'
+`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	msg := service.NewMessage([]byte("test message"))
+	msg.MetaSetMut("level", "ERROR")
+
+	result, err := proc.Process(context.Background(), msg)
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+
+	content, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatal("Failed to read destination file:", err)
+	}
+	if expected := "ERROR failed\nERROR again\n"; string(content) != expected {
+		t.Errorf("Expected destination content '%s', got '%s'", expected, string(content))
+	}
+
+	if kept, _ := result[0].MetaGet("file_lines_kept"); kept != "2" {
+		t.Errorf("Expected file_lines_kept '2', got '%s'", kept)
+	}
+	if dropped, _ := result[0].MetaGet("file_lines_dropped"); dropped != "2" {
+		t.Errorf("Expected file_lines_dropped '2', got '%s'", dropped)
+	}
+
+	// Source file should be untouched
+	if _, err := os.Stat(srcFile); err != nil {
+		t.Fatal("Source file should still exist:", err)
+	}
+}
+
+func TestFileProcessorFilterNonBooleanPredicate(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFile := filepath.Join(tempDir, "source.log")
+	destFile := filepath.Join(tempDir, "dest.log")
+
+	if err := os.WriteFile(srcFile, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal("Failed to create source file:", err)
+	}
+
+	conf := `
+operation: filter
+path: ` + srcFile + `
+destination_path: ` + destFile + `
+predicate: 'content().string()'
+`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	if _, err := proc.Process(context.Background(), service.NewMessage(nil)); err == nil {
+		t.Fatal("Expected an error for a non-boolean predicate")
+	}
+
+	if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+		t.Fatal("Destination file should not exist after a failed filter")
+	}
+	tempFiles, _ := filepath.Glob(destFile + ".tmp_*")
+	if len(tempFiles) > 0 {
+		t.Errorf("Found unexpected temporary files: %v", tempFiles)
+	}
+}
+
+func TestFileProcessorWriteEmitReceipt(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "receipt.txt")
+	testContent := "Hello, World!"
+
+	conf := `{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"emit": "receipt"
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte(testContent)))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+
+	receipt, err := result[0].AsStructured()
+	if err != nil {
+		t.Fatal("Failed to get structured receipt:", err)
+	}
+	fields, ok := receipt.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected receipt object, got %T", receipt)
+	}
+
+	if fields["path"] != testFile {
+		t.Errorf("Expected receipt path '%s', got '%v'", testFile, fields["path"])
+	}
+	if fields["bytes"] != int64(len(testContent)) {
+		t.Errorf("Expected receipt bytes %d, got '%v'", len(testContent), fields["bytes"])
+	}
+	if expected := "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"; fields["checksum"] != expected {
+		t.Errorf("Expected receipt checksum '%s', got '%v'", expected, fields["checksum"])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fields["timestamp"].(string)); err != nil {
+		t.Errorf("Expected RFC3339 receipt timestamp, got '%v'", fields["timestamp"])
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal("Failed to read created file:", err)
+	}
+	if string(content) != testContent {
+		t.Errorf("Expected file content '%s', got '%s'", testContent, string(content))
+	}
+}
+
+// chunkedFile limits each read of the wrapped file to chunk bytes and, when
+// failAfter is positive, fails once that many bytes have been read.
+type chunkedFile struct {
+	fs.File
+	chunk     int
+	failAfter int
+	read      int
+	reads     int
+}
+
+func (f *chunkedFile) Read(p []byte) (int, error) {
+	if f.failAfter > 0 && f.read >= f.failAfter {
+		return 0, errors.New("simulated read failure")
+	}
+	if len(p) > f.chunk {
+		p = p[:f.chunk]
+	}
+	n, err := f.File.Read(p)
+	f.read += n
+	f.reads++
+	return n, err
+}
+
+func TestFileProcessorMoveStreamsLargeFile(t *testing.T) {
+	const chunk = 64 * 1024
+
+	// Just over 4MiB, so that the file spans many chunks and ends mid-chunk.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 256*1024+7)
+
+	tests := []struct {
+		name      string
+		failAfter int
+	}{
+		{name: "complete"},
+		{name: "read failure", failAfter: 10 * chunk},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			srcFile := filepath.Join(tempDir, "source.bin")
+			destFile := filepath.Join(tempDir, "dest", "destination.bin")
+
+			if err := os.WriteFile(srcFile, content, 0o644); err != nil {
+				t.Fatal("Failed to create source file:", err)
+			}
+
+			var opened *chunkedFile
+			testFS := &fileProcessorTestFS{FS: ifs.OS()}
+			testFS.open = func(name string) (fs.File, error) {
+				f, err := testFS.FS.Open(name)
+				if err != nil || name != srcFile {
+					return f, err
+				}
+				opened = &chunkedFile{File: f, chunk: chunk, failAfter: test.failAfter}
+				return opened, nil
+			}
+
+			proc, err := newFileProcessorFromConfigWithFS(`{
+				"operation": "move",
+				"path": "`+srcFile+`",
+				"destination_path": "`+destFile+`"
+			}`, testFS)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			_, err = proc.Process(context.Background(), service.NewMessage(nil))
+			if opened == nil {
+				t.Fatal("Expected the source file to be opened through the filesystem")
+			}
+
+			if test.failAfter > 0 {
+				if err == nil {
+					t.Fatal("Expected the move to fail")
+				}
+				if _, err := os.Stat(destFile); !os.IsNotExist(err) {
+					t.Error("Expected no destination file after a failed move")
+				}
+				if srcContent, err := os.ReadFile(srcFile); err != nil || !bytes.Equal(srcContent, content) {
+					t.Error("Expected the source file to be left intact")
+				}
+			} else {
+				if err != nil {
+					t.Fatal("Process failed:", err)
+				}
+				if opened.reads < len(content)/chunk {
+					t.Errorf("Expected the source to be streamed in chunks, got %d reads", opened.reads)
+				}
+				destContent, err := os.ReadFile(destFile)
+				if err != nil {
+					t.Fatal("Failed to read destination file:", err)
+				}
+				if !bytes.Equal(destContent, content) {
+					t.Errorf("Expected %d bytes of destination content to match the source, got %d bytes", len(content), len(destContent))
+				}
+				if _, err := os.Stat(srcFile); !os.IsNotExist(err) {
+					t.Error("Expected source file to be deleted")
+				}
+			}
+
+			tempFiles, _ := filepath.Glob(filepath.Join(tempDir, "dest", "*.tmp_*"))
+			if len(tempFiles) > 0 {
+				t.Errorf("Found leftover temporary files: %v", tempFiles)
+			}
+		})
+	}
+}
+
+func TestFileProcessorMoveSourceDeleteFailure(t *testing.T) {
+	tests := []struct {
+		behaviour   string
+		expectErr   bool
+		expectFlags bool
+	}{
+		{behaviour: "warn"},
+		{behaviour: "error", expectErr: true},
+		{behaviour: "metadata", expectFlags: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.behaviour, func(t *testing.T) {
+			tempDir := t.TempDir()
+			srcFile := filepath.Join(tempDir, "source.txt")
+			destFile := filepath.Join(tempDir, "destination.txt")
+
+			if err := os.WriteFile(srcFile, []byte("Move me"), 0o644); err != nil {
+				t.Fatal("Failed to create source file:", err)
+			}
+
+			conf := `{
+				"operation": "move",
+				"path": "` + srcFile + `",
+				"destination_path": "` + destFile + `",
+				"on_source_delete_failure": "` + test.behaviour + `"
+			}`
+
+			proc, err := newFileProcessorFromConfigWithFS(conf, &fileProcessorTestFS{
+				FS: ifs.OS(),
+				remove: func(name string) error {
+					if name == srcFile {
+						return errors.New("simulated lock")
+					}
+					return os.Remove(name)
+				},
+			})
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+			proc.deleteRetryBackoff = time.Millisecond
+
+			result, err := proc.Process(context.Background(), service.NewMessage([]byte("test message")))
+			if test.expectErr {
+				if err == nil {
+					t.Fatal("Expected move to fail when the source cannot be deleted")
+				}
+			} else {
+				if err != nil {
+					t.Fatal("Process failed:", err)
+				}
+				flag, exists := result[0].MetaGet("file_source_delete_failed")
+				if test.expectFlags && (!exists || flag != "true") {
+					t.Errorf("Expected file_source_delete_failed 'true', got '%s'", flag)
+				}
+				if !test.expectFlags && exists {
+					t.Errorf("Expected file_source_delete_failed to be unset, got '%s'", flag)
+				}
+			}
+
+			// The copy completes regardless of the behaviour
+			for _, path := range []string{srcFile, destFile} {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("Expected '%s' to exist: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileProcessorReadCodec(t *testing.T) {
+	tempDir := t.TempDir()
+
+	lengthPrefixed := func(records ...string) []byte {
+		var b []byte
+		for _, r := range records {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(r)))
+			b = append(b, r...)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		codec    string
+		content  []byte
+		expected []string
+		errors   bool
+	}{
+		{
+			name:     "length prefixed",
+			codec:    "length_prefixed_uint32_be",
+			content:  lengthPrefixed("first", "", "third\nrecord"),
+			expected: []string{"first", "", "third\nrecord"},
+		},
+		{
+			name:    "length prefixed truncated",
+			codec:   "length_prefixed_uint32_be",
+			content: lengthPrefixed("first", "second")[:12],
+			errors:  true,
+		},
+		{
+			name:     "netstring",
+			codec:    "netstring",
+			content:  []byte("5:hello,5:world,"),
+			expected: []string{"hello", "world"},
+		},
+		{
+			name:     "lines",
+			codec:    "lines",
+			content:  []byte("a\nb\nc"),
+			expected: []string{"a", "b", "c"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, fmt.Sprintf("records_%d.bin", i))
+			if err := os.WriteFile(testFile, test.content, 0o644); err != nil {
+				t.Fatal("Failed to create test file:", err)
+			}
+
+			conf := `{
+				"operation": "read",
+				"path": "` + testFile + `",
+				"codec": "` + test.codec + `"
+			}`
+
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if test.errors {
+				if err == nil {
+					t.Fatal("Expected an error for a truncated record")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			if len(result) != len(test.expected) {
+				t.Fatalf("Expected %d messages, got %d", len(test.expected), len(result))
+			}
+			for j, expected := range test.expected {
+				contentBytes, err := result[j].AsBytes()
+				if err != nil {
+					t.Fatal("Failed to get message bytes:", err)
+				}
+				if string(contentBytes) != expected {
+					t.Errorf("Record %d: Expected '%s', got '%s'", j, expected, string(contentBytes))
+				}
+			}
+		})
+	}
+}
+
+func TestFileProcessorWriteSkipUnchanged(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "unchanged.txt")
+
+	conf := `{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"skip_unchanged": true
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	write := func(content string) string {
+		t.Helper()
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte(content)))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		skipped, _ := result[0].MetaGet("file_write_skipped")
+		return skipped
+	}
+
+	if skipped := write("first"); skipped != "false" {
+		t.Errorf("Expected initial write not to be skipped, got '%s'", skipped)
+	}
+
+	// Backdate the file so that a rewrite would be visible in its mod time
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(testFile, past, past); err != nil {
+		t.Fatal("Failed to set file times:", err)
+	}
+
+	if skipped := write("first"); skipped != "true" {
+		t.Errorf("Expected identical write to be skipped, got '%s'", skipped)
+	}
+	fileInfo, err := os.Stat(testFile)
+	if err != nil {
+		t.Fatal("Failed to stat file:", err)
+	}
+	if !fileInfo.ModTime().Equal(past) {
+		t.Errorf("Expected mod time to be untouched, got %v", fileInfo.ModTime())
+	}
+
+	// Same size but different content must still be written
+	if skipped := write("other"); skipped != "false" {
+		t.Errorf("Expected changed write not to be skipped, got '%s'", skipped)
+	}
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal("Failed to read file:", err)
+	}
+	if string(content) != "other" {
+		t.Errorf("Expected file content 'other', got '%s'", string(content))
+	}
+}
+
+func TestFileProcessorAuditOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "audited.txt")
+
+	conf := `{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"encode": "hex",
+		"ensure_trailing_newline": true,
+		"audit_output": "audit"
+	}`
+
+	var records []any
+	proc, err := newFileProcessorFromConfigWithMock(conf, func(m *mock.Manager) {
+		m.Outputs["audit"] = mock.OutputWriter(func(ctx context.Context, tran message.Transaction) error {
+			for _, part := range tran.Payload {
+				record, err := part.AsStructured()
+				if err != nil {
+					return err
+				}
+				records = append(records, record)
+			}
+			return tran.Ack(ctx, nil)
+		})
+	})
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("Audit me"))); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	// Writing beneath a regular file fails
+	proc.conf.Path, _ = service.NewInterpolatedString(filepath.Join(testFile, "nested.txt"))
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("Audit me"))); err == nil {
+		t.Fatal("Expected write beneath a file to fail")
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %d", len(records))
+	}
+
+	success := records[0].(map[string]any)
+	if success["operation"] != "write" || success["path"] != testFile || success["result"] != "success" || success["bytes"] != int64(17) {
+		t.Errorf("Unexpected success audit record: %v", success)
+	}
+
+	failure := records[1].(map[string]any)
+	if failure["result"] != "error" || failure["error"] == nil {
+		t.Errorf("Unexpected failure audit record: %v", failure)
+	}
+}
+
+func TestFileProcessorAuditOutputNotFound(t *testing.T) {
+	conf := `{
+		"operation": "stat",
+		"path": "/tmp/foo",
+		"audit_output": "missing"
+	}`
+
+	if _, err := newFileProcessorFromConfig(conf); err == nil {
+		t.Fatal("Expected an error for a missing audit output resource")
+	}
+}
+
+func TestFileProcessorVerifyPermissions(t *testing.T) {
+	tempDir := t.TempDir()
+	regularFile := filepath.Join(tempDir, "regular.txt")
+	if err := os.WriteFile(regularFile, []byte("not a directory"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	readOnlyFS := &fileProcessorTestFS{
+		FS: ifs.OS(),
+		openFile: func(name string, flag int, perm fs.FileMode) (fs.File, error) {
+			if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+				return nil, fs.ErrPermission
+			}
+			return os.OpenFile(name, flag, perm)
+		},
+	}
+
+	tests := []struct {
+		name      string
+		conf      string
+		fs        ifs.FS
+		expectErr bool
+	}{
+		{
+			name: "writable directory yet to be created",
+			conf: `{"operation": "write", "path": "` + filepath.Join(tempDir, "a", "b", "out.txt") + `", "verify_permissions": true}`,
+			fs:   ifs.OS(),
+		},
+		{
+			name:      "read only filesystem",
+			conf:      `{"operation": "write", "path": "` + filepath.Join(tempDir, "out.txt") + `", "verify_permissions": true}`,
+			fs:        readOnlyFS,
+			expectErr: true,
+		},
+		{
+			name:      "read only filesystem destination",
+			conf:      `{"operation": "move", "path": "${! @src }", "destination_path": "` + filepath.Join(tempDir, "out.txt") + `", "verify_permissions": true}`,
+			fs:        readOnlyFS,
+			expectErr: true,
+		},
+		{
+			name:      "parent is a regular file",
+			conf:      `{"operation": "write", "path": "` + filepath.Join(regularFile, "out.txt") + `", "verify_permissions": true}`,
+			fs:        ifs.OS(),
+			expectErr: true,
+		},
+		{
+			name: "dynamic paths are skipped",
+			conf: `{"operation": "write", "path": "` + filepath.Join(tempDir, "${! @name }.txt") + `", "verify_permissions": true}`,
+			fs:   readOnlyFS,
+		},
+		{
+			name: "disabled by default",
+			conf: `{"operation": "write", "path": "` + filepath.Join(tempDir, "out.txt") + `"}`,
+			fs:   readOnlyFS,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newFileProcessorFromConfigWithFS(test.conf, test.fs)
+			if test.expectErr && err == nil {
+				t.Fatal("Expected permission check to fail")
+			}
+			if !test.expectErr && err != nil {
+				t.Fatal("Expected permission check to pass, got:", err)
+			}
+
+			probes, _ := filepath.Glob(filepath.Join(tempDir, ".bento_permission_check*"))
+			if len(probes) > 0 {
+				t.Errorf("Found leftover permission check files: %v", probes)
+			}
+		})
+	}
+}
+
+func TestFileProcessorTypedMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "typed.txt")
+
+	if err := os.WriteFile(testFile, []byte("Hello, World!"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	conf := `{
+		"operation": "stat",
+		"path": "` + testFile + `"
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	size, exists := result[0].MetaGetMut("file_size")
+	if !exists {
+		t.Fatal("Expected file_size metadata to be set")
+	}
+	if size != int64(13) {
+		t.Errorf("Expected typed file_size int64(13), got %T(%v)", size, size)
+	}
+
+	exec, err := bloblang.Parse(`metadata("file_size") > 10 && metadata("file_size") < 20 && metadata("file_is_dir") == false && metadata("file_mod_time_unix") > 0`)
+	if err != nil {
+		t.Fatal("Failed to parse mapping:", err)
+	}
+
+	res, err := result[0].BloblangQueryValue(exec)
+	if err != nil {
+		t.Fatal("Failed to execute mapping:", err)
+	}
+	if res != true {
+		t.Errorf("Expected numeric metadata comparisons to pass, got %v", res)
+	}
+}
+
+func TestFileProcessorBulkDelete(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var existing []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+		existing = append(existing, path)
+	}
+	missing := filepath.Join(tempDir, "missing.txt")
+
+	for _, errorMode := range []string{"collect", "fail_fast"} {
+		t.Run(errorMode, func(t *testing.T) {
+			for _, path := range existing {
+				if err := os.WriteFile(path, []byte("delete me"), 0o644); err != nil {
+					t.Fatal("Failed to create test file:", err)
+				}
+			}
+
+			conf := fmt.Sprintf(`
+operation: delete
+paths: 'root = this.files.map_each(f -> "%s/" + f)'
+error_mode: %v
+`, tempDir, errorMode)
+
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			msg := service.NewMessage([]byte(`{"files":["a.txt","missing.txt","b.txt"]}`))
+			result, err := proc.Process(context.Background(), msg)
+			if errorMode == "fail_fast" {
+				if err == nil {
+					t.Fatal("Expected fail_fast delete to fail on a missing file")
+				}
+				// The path after the failure is left untouched
+				if _, err := os.Stat(existing[1]); err != nil {
+					t.Errorf("Expected '%s' to remain after fail_fast failure", existing[1])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			for _, path := range existing {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("Expected '%s' to be deleted", path)
+				}
+			}
+
+			results, _ := result[0].MetaGetMut("file_delete_results")
+			resultsMap := results.(map[string]any)
+			if resultsMap[existing[0]] != true || resultsMap[existing[1]] != true || resultsMap[missing] != false {
+				t.Errorf("Unexpected delete results: %v", resultsMap)
+			}
+
+			failures, _ := result[0].MetaGetMut("file_delete_errors")
+			failuresMap := failures.(map[string]any)
+			if len(failuresMap) != 1 || failuresMap[missing] == nil {
+				t.Errorf("Unexpected delete errors: %v", failuresMap)
+			}
+		})
+	}
+}
+
+func TestFileProcessorWriteDirectIO(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "direct.bin")
+
+	conf := `{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"direct_io": true
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	for _, size := range []int{0, 100, 4096, 3*4096 + 17, 2*1024*1024 + 5} {
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = byte(i % 251)
+		}
+
+		if _, err := proc.Process(context.Background(), service.NewMessage(content)); err != nil {
+			t.Fatalf("Process failed for size %d: %v", size, err)
+		}
+
+		written, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Fatal("Failed to read written file:", err)
+		}
+		if !bytes.Equal(written, content) {
+			t.Errorf("Content mismatch for size %d: got %d bytes", size, len(written))
+		}
+	}
+}
+
+func TestFileProcessorSuccessProcessors(t *testing.T) {
+	tempDir := t.TempDir()
+
+	conf := `{
+		"operation": "write",
+		"path": "` + tempDir + `/${! meta(\"dir\") }/out.txt",
+		"success_processors": [
+			{ "mapping": "root = \"written \" + content()" }
+		]
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	defer proc.Close(context.Background())
+
+	msg := service.NewMessage([]byte("hello"))
+	msg.MetaSetMut("dir", "ok")
+	result, err := proc.Process(context.Background(), msg)
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+	content, _ := result[0].AsBytes()
+	if string(content) != "written hello" {
+		t.Errorf("Expected success processors to run, got '%s'", content)
+	}
+
+	// A failed operation must not run the success processors
+	if err := os.WriteFile(filepath.Join(tempDir, "blocker"), []byte("file"), 0o644); err != nil {
+		t.Fatal("Failed to create blocking file:", err)
+	}
+	msg = service.NewMessage([]byte("hello"))
+	msg.MetaSetMut("dir", "blocker")
+	if _, err := proc.Process(context.Background(), msg); err == nil {
+		t.Error("Expected write beneath a file to fail")
+	}
+}
+
+func TestFileProcessorChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "checksum.txt")
+	testContent := "Hello, World!"
+	if err := os.WriteFile(testFile, []byte(testContent), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	tests := []struct {
+		algorithm string
+		checksum  string
+	}{
+		{algorithm: "md5", checksum: "65a8e27d8879283831b664bd8b7f0ad4"},
+		{algorithm: "sha1", checksum: "0a0a9f2a6772942557ab5355d76af442f8f65e01"},
+		{algorithm: "sha256", checksum: "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"},
+		{algorithm: "crc32", checksum: "ec4ac3d0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.algorithm, func(t *testing.T) {
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "checksum",
+				"path": "` + testFile + `",
+				"checksum_algorithm": "` + test.algorithm + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage([]byte("original")))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if len(result) != 1 {
+				t.Fatalf("Expected 1 message, got %d", len(result))
+			}
+
+			if v, _ := result[0].MetaGet("file_checksum"); v != test.checksum {
+				t.Errorf("Expected file_checksum '%s', got '%s'", test.checksum, v)
+			}
+			if v, _ := result[0].MetaGet("file_checksum_algorithm"); v != test.algorithm {
+				t.Errorf("Expected file_checksum_algorithm '%s', got '%s'", test.algorithm, v)
+			}
+			if content, _ := result[0].AsBytes(); string(content) != "original" {
+				t.Errorf("Expected message content to be unchanged, got '%s'", content)
+			}
+		})
+	}
+}
+
+func TestFileProcessorVerify(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "download.bin")
+	if err := os.WriteFile(testFile, []byte("hello world"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		checksum  string
+		failOn    bool
+		valid     bool
+		wantErr   bool
+	}{
+		{name: "sha256 match", algorithm: "sha256", checksum: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", valid: true},
+		{name: "uppercase match", algorithm: "sha256", checksum: "B94D27B9934D3E08A52E52D7DA7DABFAC484EFE37A5380EE9088F7ACE2EFCDE9", valid: true},
+		{name: "md5 match", algorithm: "md5", checksum: "5eb63bbbe01eeed093cb22bb8f5acdc3", valid: true},
+		{name: "mismatch", algorithm: "sha256", checksum: "deadbeef", valid: false},
+		{name: "mismatch fails", algorithm: "sha256", checksum: "deadbeef", failOn: true, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := fmt.Sprintf(`{
+				"operation": "verify",
+				"path": %q,
+				"expected_checksum": "${! meta(\"checksum\") }",
+				"checksum_algorithm": %q,
+				"fail_on_mismatch": %v
+			}`, testFile, test.algorithm, test.failOn)
+
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			msg := service.NewMessage([]byte("content"))
+			msg.MetaSetMut("checksum", test.checksum)
+			result, err := proc.Process(context.Background(), msg)
+			if test.wantErr {
+				if err == nil {
+					t.Error("Expected checksum mismatch error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			valid, ok := result[0].MetaGetMut("file_checksum_valid")
+			if !ok || valid != test.valid {
+				t.Errorf("Expected file_checksum_valid to be %v, got %v", test.valid, valid)
+			}
+			content, _ := result[0].AsBytes()
+			if string(content) != "content" {
+				t.Errorf("Expected content to be unchanged, got '%s'", content)
+			}
+		})
+	}
+}
+
+func TestFileProcessorRenameRetries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("busy rename errors are simulated using ETXTBSY, which is not transient on Windows")
+	}
+
+	tests := []struct {
+		name      string
+		failures  int
+		expectErr bool
+	}{
+		{name: "succeeds after retries", failures: 2},
+		{name: "retries exhausted", failures: 5, expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "busy.txt")
+
+			conf := `{
+				"operation": "write",
+				"path": "` + testFile + `",
+				"rename_retries": 3,
+				"rename_retry_delay": "1ms"
+			}`
+
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			var attempts int
+			proc.rename = func(oldpath, newpath string) error {
+				attempts++
+				if attempts <= test.failures {
+					return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ETXTBSY}
+				}
+				return os.Rename(oldpath, newpath)
+			}
+
+			_, err = proc.Process(context.Background(), service.NewMessage([]byte("busy content")))
+			if test.expectErr {
+				if err == nil {
+					t.Fatal("Expected write to fail once retries are exhausted")
+				}
+				if attempts != 4 {
+					t.Errorf("Expected 4 rename attempts, got %d", attempts)
+				}
+			} else {
+				if err != nil {
+					t.Fatal("Process failed:", err)
+				}
+				content, err := os.ReadFile(testFile)
+				if err != nil {
+					t.Fatal("Failed to read written file:", err)
+				}
+				if string(content) != "busy content" {
+					t.Errorf("Expected 'busy content', got '%s'", content)
+				}
+			}
+
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatal("Failed to read directory:", err)
+			}
+			for _, entry := range entries {
+				if strings.Contains(entry.Name(), ".tmp_") {
+					t.Errorf("Temporary file was not cleaned up: %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestFileProcessorTreeChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+	for path, content := range map[string]string{
+		"a.txt":       "alpha",
+		"sub/b.txt":   "bravo",
+		"sub/c/d.txt": "delta",
+	} {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal("Failed to create directory:", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+	}
+
+	conf := `{
+		"operation": "tree_checksum",
+		"path": "` + tempDir + `"
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	checksum := func() string {
+		t.Helper()
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte("content")))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		sum, ok := result[0].MetaGet("dir_checksum")
+		if !ok || sum == "" {
+			t.Fatal("Expected dir_checksum metadata to be set")
+		}
+		return sum
+	}
+
+	initial := checksum()
+	if again := checksum(); again != initial {
+		t.Errorf("Expected checksum to be deterministic, got '%s' and '%s'", initial, again)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "sub/c/d.txt"), []byte("DELTA"), 0o644); err != nil {
+		t.Fatal("Failed to modify test file:", err)
+	}
+	modified := checksum()
+	if modified == initial {
+		t.Error("Expected checksum to change when a file is modified")
+	}
+
+	if err := os.Rename(filepath.Join(tempDir, "a.txt"), filepath.Join(tempDir, "e.txt")); err != nil {
+		t.Fatal("Failed to rename test file:", err)
+	}
+	if renamed := checksum(); renamed == modified {
+		t.Error("Expected checksum to change when a file is renamed")
+	}
+}
+
+func TestFileProcessorSamePath(t *testing.T) {
+	for _, op := range []string{"move", "rename"} {
+		for _, behavior := range []string{"skip", "error"} {
+			t.Run(op+" "+behavior, func(t *testing.T) {
+				tempDir := t.TempDir()
+				testFile := filepath.Join(tempDir, "same.txt")
+				if err := os.WriteFile(testFile, []byte("precious"), 0o644); err != nil {
+					t.Fatal("Failed to create test file:", err)
+				}
+
+				conf := `{
+					"operation": "` + op + `",
+					"path": "` + testFile + `",
+					"destination_path": "` + tempDir + `/./same.txt",
+					"same_path_behavior": "` + behavior + `"
+				}`
+
+				proc, err := newFileProcessorFromConfig(conf)
+				if err != nil {
+					t.Fatal("Failed to create processor:", err)
+				}
+
+				result, err := proc.Process(context.Background(), service.NewMessage([]byte("content")))
+				if behavior == "error" {
+					if err == nil {
+						t.Error("Expected identical paths to fail")
+					}
+				} else {
+					if err != nil {
+						t.Fatal("Process failed:", err)
+					}
+					if skipped, _ := result[0].MetaGetMut("file_skipped"); skipped != true {
+						t.Errorf("Expected file_skipped to be true, got %v", skipped)
+					}
+				}
+
+				content, err := os.ReadFile(testFile)
+				if err != nil {
+					t.Fatal("Expected file to remain in place:", err)
+				}
+				if string(content) != "precious" {
+					t.Errorf("Expected file content to be untouched, got '%s'", content)
+				}
+			})
+		}
+	}
+}
+
+func TestFileProcessorDeadLetter(t *testing.T) {
+	tempDir := t.TempDir()
+	missingFile := filepath.Join(tempDir, "missing.txt")
+
+	conf := `{
+		"operation": "delete",
+		"path": "` + missingFile + `",
+		"dead_letter": "dlq"
+	}`
+
+	var deadLetters []*message.Part
+	deliver := true
+	proc, err := newFileProcessorFromConfigWithMock(conf, func(m *mock.Manager) {
+		m.Outputs["dlq"] = mock.OutputWriter(func(ctx context.Context, tran message.Transaction) error {
+			if !deliver {
+				return tran.Ack(ctx, errors.New("simulated delivery failure"))
+			}
+			deadLetters = append(deadLetters, tran.Payload...)
+			return tran.Ack(ctx, nil)
+		})
+	})
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte("orphan")))
+	if err != nil {
+		t.Fatal("Expected dead lettered message not to fail:", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected dead lettered message to be dropped, got %d messages", len(result))
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(deadLetters))
+	}
+	if string(deadLetters[0].AsBytes()) != "orphan" {
+		t.Errorf("Expected original content in dead letter, got '%s'", deadLetters[0].AsBytes())
+	}
+	if deadLetters[0].MetaGetStr("file_error") == "" {
+		t.Error("Expected file_error metadata on dead letter")
+	}
+
+	deliver = false
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("orphan"))); err == nil {
+		t.Error("Expected original error when dead letter delivery fails")
+	}
+}
+
+func TestFileProcessorStaleTempFileSweep(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "target.txt")
+
+	past := time.Now().Add(-2 * time.Hour)
+	files := map[string]bool{
+		"target.txt.tmp_1234_0123456789abcdef": false, // stale, removed
+		"target.txt.tmp_0123456789abcdef":      false, // stale without pid, removed
+		"target.txt.tmp_notours":               true,  // different pattern, kept
+		"other.txt.tmp_1234_0123456789abcdef":  true,  // different target, kept
+		"target.txt.tmp_5678_fedcba9876543210": true,  // recent, kept
+	}
+	for name := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+		if name != "target.txt.tmp_5678_fedcba9876543210" {
+			if err := os.Chtimes(path, past, past); err != nil {
+				t.Fatal("Failed to set file times:", err)
+			}
+		}
+	}
+
+	conf := `{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"stale_temp_file_age": "1h"
+	}`
+
+	if _, err := newFileProcessorFromConfig(conf); err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(tempDir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("Expected '%s' kept=%v, but exists=%v", name, kept, exists)
+		}
+	}
+}
+
+func TestFileProcessorReadLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "many.txt")
+	if err := os.WriteFile(testFile, []byte("one\ntwo\nthree\nfour\nfive\n"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	for _, reader := range []string{`"scanner": { "lines": {} }`, `"codec": "lines"`} {
+		t.Run(reader, func(t *testing.T) {
+			conf := `{
+				"operation": "read",
+				"path": "` + testFile + `",
+				"limit": 2,
+				` + reader + `
+			}`
+
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if len(result) != 2 {
+				t.Fatalf("Expected 2 messages, got %d", len(result))
+			}
+			for i, expected := range []string{"one", "two"} {
+				content, _ := result[i].AsBytes()
+				if string(content) != expected {
+					t.Errorf("Expected message %d to be '%s', got '%s'", i, expected, content)
+				}
+			}
+		})
+	}
+}
+
+func TestFileProcessorReadFollowSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	targetFile := filepath.Join(tempDir, "secret.txt")
+	linkFile := filepath.Join(tempDir, "link.txt")
+	if err := os.WriteFile(targetFile, []byte("secret"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+	if err := os.Symlink(targetFile, linkFile); err != nil {
+		t.Skip("Symbolic links are not supported:", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		follow    bool
+		expectErr bool
+	}{
+		{name: "follow link", path: linkFile, follow: true},
+		{name: "refuse link", path: linkFile, follow: false, expectErr: true},
+		{name: "regular file", path: targetFile, follow: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := fmt.Sprintf(`{
+				"operation": "read",
+				"path": %q,
+				"follow_symlinks": %v,
+				"scanner": { "to_the_end": {} }
+			}`, test.path, test.follow)
+
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if test.expectErr {
+				if err == nil {
+					t.Error("Expected reading through a symbolic link to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			content, _ := result[0].AsBytes()
+			if string(content) != "secret" {
+				t.Errorf("Expected 'secret', got '%s'", content)
+			}
+		})
+	}
+}
+
+func TestFileProcessorReadSymlinksResourceFS(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "data.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "data.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skip("Symbolic links are not supported:", err)
+	}
+
+	read := func(filesystem ifs.FS, path string) (service.MessageBatch, error) {
+		proc, err := newFileProcessorFromConfigWithFS(`{
+			"operation": "read",
+			"path": "`+path+`",
+			"follow_symlinks": false,
+			"scanner": { "to_the_end": {} }
+		}`, filesystem)
+		if err != nil {
+			t.Fatal("Failed to create processor:", err)
+		}
+		return proc.Process(context.Background(), service.NewMessage(nil))
+	}
+
+	// Paths are checked within the virtual filesystem rather than the OS.
+	virtualFS := &rootedTestFS{root: root}
+	result, err := read(virtualFS, "/data.txt")
+	if err != nil {
+		t.Fatal("Expected a regular file within the virtual filesystem to be read:", err)
+	}
+	if content, _ := result[0].AsBytes(); string(content) != "data" {
+		t.Errorf("Expected 'data', got '%s'", content)
+	}
+	if _, err := read(virtualFS, "/link.txt"); err == nil || !strings.Contains(err.Error(), "symbolic link") {
+		t.Errorf("Expected a symbolic link within the virtual filesystem to be refused, got: %v", err)
+	}
+
+	// Filesystems that cannot describe symbolic links skip the check.
+	if _, err := read(&fileProcessorTestFS{FS: ifs.OS()}, filepath.Join(root, "link.txt")); err != nil {
+		t.Errorf("Expected the check to be skipped without Lstat support, got: %v", err)
+	}
+}
+
+func TestFileProcessorWriteCheckSpace(t *testing.T) {
+	if !spaceCheckSupported {
+		t.Skip("Checking available disk space is not supported on this platform")
+	}
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "nested", "space.txt")
+
+	conf := `{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"check_space": true
+	}`
+
+	proc, err := newFileProcessorFromConfig(conf)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte("content")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	available, ok := result[0].MetaGetMut("file_space_available")
+	if !ok {
+		t.Fatal("Expected file_space_available metadata to be set")
+	}
+	if n, _ := available.(int64); n <= 0 {
+		t.Errorf("Expected positive available space, got %v", available)
+	}
+
+	// Refuse writes that would leave less than an impossible amount free
+	proc.conf.MinFreeSpace = 1 << 62
+	if err := os.Remove(testFile); err != nil {
+		t.Fatal("Failed to remove test file:", err)
+	}
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("content"))); err == nil {
+		t.Error("Expected write to be refused")
+	}
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Error("Expected refused write not to create the file")
+	}
+}
+
+func TestFileProcessorEncodeDecode(t *testing.T) {
+	for _, test := range []struct {
+		encoding string
+		onDisk   string
+	}{
+		{encoding: "base64", onDisk: "aGVsbG8/d29ybGQ+"},
+		{encoding: "base64url", onDisk: "aGVsbG8_d29ybGQ-"},
+		{encoding: "hex", onDisk: "68656c6c6f3f776f726c643e"},
+	} {
+		t.Run(test.encoding, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "encoded.txt")
+
+			writeProc, err := newFileProcessorFromConfig(`{
+				"operation": "write",
+				"path": "` + testFile + `",
+				"encode": "` + test.encoding + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+			if _, err := writeProc.Process(context.Background(), service.NewMessage([]byte("hello?world>"))); err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			content, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatal("Failed to read written file:", err)
+			}
+			if string(content) != test.onDisk {
+				t.Errorf("Expected '%s' on disk, got '%s'", test.onDisk, content)
+			}
+
+			readProc, err := newFileProcessorFromConfig(`{
+				"operation": "read",
+				"path": "` + testFile + `",
+				"decode": "` + test.encoding + `",
+				"scanner": { "to_the_end": {} }
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+			result, err := readProc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			decoded, _ := result[0].AsBytes()
+			if string(decoded) != "hello?world>" {
+				t.Errorf("Expected decoded content 'hello?world>', got '%s'", decoded)
+			}
+		})
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "move",
+		"path": "/tmp/a",
+		"destination_path": "/tmp/b",
+		"encode": "hex"
+	}`); err == nil || !strings.Contains(err.Error(), "encode is only supported") {
+		t.Errorf("Expected encode to be rejected for the move operation, got %v", err)
+	}
+}
+
+func TestFileProcessorStatIdentity(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "original.txt")
+	linkFile := filepath.Join(tempDir, "hardlink.txt")
+	otherFile := filepath.Join(tempDir, "other.txt")
+	for _, path := range []string{testFile, otherFile} {
+		if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+	}
+	if err := os.Link(testFile, linkFile); err != nil {
+		t.Skip("Hard links are not supported:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "stat",
+		"path": "${! content() }"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	identity := func(path string) (any, any) {
+		t.Helper()
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte(path)))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		inode, _ := result[0].MetaGetMut("file_inode")
+		device, _ := result[0].MetaGetMut("file_device")
+		return inode, device
+	}
+
+	inode, device := identity(testFile)
+	if inode == nil {
+		t.Skip("Inode and device numbers are not available on this platform")
+	}
+	if _, ok := inode.(int64); !ok {
+		t.Fatalf("Expected file_inode to be an integer, got %T", inode)
+	}
+
+	linkInode, linkDevice := identity(linkFile)
+	if linkInode != inode || linkDevice != device {
+		t.Errorf("Expected hard link to share inode and device, got %v/%v and %v/%v", inode, device, linkInode, linkDevice)
+	}
+	if otherInode, _ := identity(otherFile); otherInode == inode {
+		t.Error("Expected distinct files to have different inodes")
+	}
+}
+
+func TestFileProcessorReadByteChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "chunks.bin")
+	if err := os.WriteFile(testFile, []byte("abcdefghij"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"byte_chunk_size": "4B"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	expected := []struct {
+		content string
+		offset  int64
+	}{
+		{"abcd", 0},
+		{"efgh", 4},
+		{"ij", 8},
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(result))
+	}
+	for i, e := range expected {
+		content, _ := result[i].AsBytes()
+		if string(content) != e.content {
+			t.Errorf("Expected chunk %d to be '%s', got '%s'", i, e.content, content)
+		}
+		if offset, _ := result[i].MetaGetMut("file_chunk_offset"); offset != e.offset {
+			t.Errorf("Expected chunk %d offset %d, got %v", i, e.offset, offset)
+		}
+	}
+}
+
+func TestFileProcessorWriteEnsureTrailingNewline(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "lines.txt")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"ensure_trailing_newline": true
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	for input, expected := range map[string]string{
+		"record":     "record\n",
+		"record\n":   "record\n",
+		"":           "\n",
+		"a\nb\n\n":   "a\nb\n\n",
+		"a\r\nb\r\n": "a\r\nb\r\n",
+	} {
+		msg := service.NewMessage([]byte(input))
+		if _, err := proc.Process(context.Background(), msg); err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		content, err := os.ReadFile(testFile)
+		if err != nil {
+			t.Fatal("Failed to read written file:", err)
+		}
+		if string(content) != expected {
+			t.Errorf("Expected %q to be written as %q, got %q", input, expected, content)
+		}
+		if original, _ := msg.AsBytes(); string(original) != input {
+			t.Errorf("Expected message content to be unchanged, got %q", original)
+		}
+	}
+
+	appendFile := filepath.Join(tempDir, "appended.txt")
+	appender, err := newFileProcessorFromConfig(`{
+		"operation": "append",
+		"path": "` + appendFile + `",
+		"ensure_trailing_newline": true
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	for _, input := range []string{"first", "second\n", "third"} {
+		if _, err := appender.Process(context.Background(), service.NewMessage([]byte(input))); err != nil {
+			t.Fatal("Process failed:", err)
+		}
+	}
+	content, err := os.ReadFile(appendFile)
+	if err != nil {
+		t.Fatal("Failed to read appended file:", err)
+	}
+	if string(content) != "first\nsecond\nthird\n" {
+		t.Errorf("Expected each append to end with a newline, got %q", content)
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"ensure_trailing_newline": true
+	}`); err == nil || !strings.Contains(err.Error(), "ensure_trailing_newline is only supported") {
+		t.Errorf("Expected ensure_trailing_newline to be rejected for the read operation, got %v", err)
+	}
+}
+
+func TestFileProcessorPatch(t *testing.T) {
+	original := "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+
+	tests := []struct {
+		name       string
+		original   string
+		diff       string
+		onConflict string
+		expected   string
+		applied    int64
+		failed     int64
+		errors     bool
+	}{
+		{
+			name:     "single hunk",
+			original: original,
+			diff: `--- a/file.txt
++++ b/file.txt
+@@ -2,3 +2,3 @@
+ two
+-three
++THREE
+ four
+`,
+			expected: "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+			applied:  1,
+		},
+		{
+			name:     "offset hunks",
+			original: "zero\n" + original,
+			diff: `@@ -1,2 +1,3 @@
+ one
++one and a half
+ two
+@@ -6,2 +7,1 @@
+ six
+-seven
+`,
+			expected: "zero\none\none and a half\ntwo\nthree\nfour\nfive\nsix\n",
+			applied:  2,
+		},
+		{
+			name:     "no newline at end of file",
+			original: "one\ntwo",
+			diff: `@@ -1,2 +1,2 @@
+ one
+-two
+\ No newline at end of file
++two
+`,
+			expected: "one\ntwo\n",
+			applied:  1,
+		},
+		{
+			name:     "conflict errors",
+			original: original,
+			diff: `@@ -2,1 +2,1 @@
+-deux
++DEUX
+`,
+			errors: true,
+		},
+		{
+			name:       "conflict skipped",
+			original:   original,
+			onConflict: "skip",
+			diff: `@@ -2,1 +2,1 @@
+-deux
++DEUX
+@@ -5,1 +5,1 @@
+-five
++FIVE
+`,
+			expected: "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\n",
+			applied:  1,
+			failed:   1,
+		},
+		{
+			name:     "multiple files",
+			original: original,
+			diff: `--- a/one.txt
++++ b/one.txt
+@@ -1,1 +1,1 @@
+-one
++ONE
+--- a/two.txt
++++ b/two.txt
+@@ -1,1 +1,1 @@
+-two
++TWO
+`,
+			errors: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "file.txt")
+			if err := os.WriteFile(testFile, []byte(test.original), 0o644); err != nil {
+				t.Fatal("Failed to create test file:", err)
+			}
+
+			onConflict := test.onConflict
+			if onConflict == "" {
+				onConflict = "error"
+			}
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "patch",
+				"path": "` + testFile + `",
+				"on_conflict": "` + onConflict + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage([]byte(test.diff)))
+			content, readErr := os.ReadFile(testFile)
+			if readErr != nil {
+				t.Fatal("Failed to read file:", readErr)
+			}
+			if test.errors {
+				if err == nil {
+					t.Error("Expected patch to fail")
+				}
+				if string(content) != test.original {
+					t.Errorf("Expected file to be unmodified, got %q", content)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if string(content) != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, content)
+			}
+			if applied, _ := result[0].MetaGetMut("file_patch_hunks_applied"); applied != test.applied {
+				t.Errorf("Expected %d hunks applied, got %v", test.applied, applied)
+			}
+			if failed, _ := result[0].MetaGetMut("file_patch_hunks_failed"); failed != test.failed {
+				t.Errorf("Expected %d hunks failed, got %v", test.failed, failed)
+			}
+		})
+	}
+}
+
+func TestFileProcessorReadOffsetCache(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "growing.log")
+	if err := os.WriteFile(testFile, []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfigWithMock(`{
+		"operation": "read",
+		"path": "`+testFile+`",
+		"codec": "lines",
+		"offset_cache": "offsets",
+		"commit_offsets": true
+	}`, func(m *mock.Manager) {
+		m.Caches["offsets"] = map[string]mock.CacheItem{}
+	})
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	read := func() []string {
+		t.Helper()
+		result, err := proc.Process(context.Background(), service.NewMessage(nil))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		var records []string
+		for _, m := range result {
+			if content, _ := m.AsBytes(); len(content) > 0 {
+				records = append(records, string(content))
+			}
+		}
+		return records
+	}
+	appendLines := func(lines string) {
+		t.Helper()
+		f, err := os.OpenFile(testFile, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal("Failed to open test file:", err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(lines); err != nil {
+			t.Fatal("Failed to append to test file:", err)
+		}
+	}
+
+	if records := read(); strings.Join(records, ",") != "one,two" {
+		t.Errorf("Expected initial read of 'one,two', got %v", records)
+	}
+	if records := read(); len(records) != 0 {
+		t.Errorf("Expected no new records, got %v", records)
+	}
+
+	appendLines("three\nfour\n")
+	if records := read(); strings.Join(records, ",") != "three,four" {
+		t.Errorf("Expected appended records 'three,four', got %v", records)
+	}
+
+	// A truncated file is read from the beginning
+	if err := os.WriteFile(testFile, []byte("new\n"), 0o644); err != nil {
+		t.Fatal("Failed to truncate test file:", err)
+	}
+	if records := read(); strings.Join(records, ",") != "new" {
+		t.Errorf("Expected truncated file to be reread, got %v", records)
+	}
+}
+
+func TestFileProcessorReadOffsetCacheDeferredCommit(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "growing.log")
+	if err := os.WriteFile(testFile, []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfigWithMock(`{
+		"operation": "read",
+		"path": "`+testFile+`",
+		"codec": "lines",
+		"offset_cache": "offsets"
+	}`, func(m *mock.Manager) {
+		m.Caches["offsets"] = map[string]mock.CacheItem{}
+	})
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	read := func() service.MessageBatch {
+		t.Helper()
+		result, err := proc.Process(context.Background(), service.NewMessage(nil))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		return result
+	}
+	records := func(batch service.MessageBatch) string {
+		var records []string
+		for _, m := range batch {
+			if content, _ := m.AsBytes(); len(content) > 0 {
+				records = append(records, string(content))
+			}
+		}
+		return strings.Join(records, ",")
+	}
+	// Store the offset in the manner of a cache output following delivery
+	commit := func(msg *service.Message) {
+		t.Helper()
+		key, _ := msg.MetaGet("file_offset_key")
+		offset, _ := msg.MetaGet("file_read_end_offset")
+		if err := proc.nm.AccessCache(context.Background(), "offsets", func(c service.Cache) {
+			err = c.Set(context.Background(), key, []byte(offset), nil)
+		}); err != nil {
+			t.Fatal("Failed to access cache:", err)
+		}
+		if err != nil {
+			t.Fatal("Failed to store offset:", err)
+		}
+	}
+
+	first := read()
+	if got := records(first); got != "one,two" {
+		t.Fatalf("Expected initial read of 'one,two', got '%s'", got)
+	}
+	if key, _ := first[0].MetaGet("file_offset_key"); key != testFile {
+		t.Errorf("Expected file_offset_key '%s', got '%s'", testFile, key)
+	}
+	if end, _ := first[1].MetaGetMut("file_read_end_offset"); end != int64(8) {
+		t.Errorf("Expected file_read_end_offset 8, got %v", end)
+	}
+
+	// Records are read again until their offset is stored
+	if got := records(read()); got != "one,two" {
+		t.Errorf("Expected undelivered records to be read again, got '%s'", got)
+	}
+
+	commit(first[0])
+	if got := records(read()); got != "" {
+		t.Errorf("Expected no new records once the offset is stored, got '%s'", got)
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"codec": "lines",
+		"commit_offsets": true
+	}`); err == nil {
+		t.Error("Expected commit_offsets to require offset_cache")
+	}
+}
+
+func TestFileProcessorWriteToDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	targetDir := filepath.Join(tempDir, "existing")
+	if err := os.Mkdir(targetDir, 0o755); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+	srcFile := filepath.Join(tempDir, "source.txt")
+	if err := os.WriteFile(srcFile, []byte("content"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	for name, conf := range map[string]string{
+		"write": `{
+			"operation": "write",
+			"path": "` + targetDir + `"
+		}`,
+		"move": `{
+			"operation": "move",
+			"path": "` + srcFile + `",
+			"destination_path": "` + targetDir + `"
+		}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			proc, err := newFileProcessorFromConfig(conf)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			_, err = proc.Process(context.Background(), service.NewMessage([]byte("content")))
+			if err == nil || !strings.Contains(err.Error(), "path is a directory") {
+				t.Errorf("Expected a path is a directory error, got %v", err)
+			}
+
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatal("Failed to read directory:", err)
+			}
+			for _, entry := range entries {
+				if strings.Contains(entry.Name(), ".tmp_") {
+					t.Errorf("Unexpected temporary file: %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestFileProcessorReflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		framing  string
+		codec    string
+		expected string
+		records  int64
+	}{
+		{
+			name:     "csv to json lines",
+			input:    "id,name\n1,foo\n2,bar\n",
+			framing:  `"scanner": { "csv": {} }`,
+			codec:    "lines",
+			expected: "{\"id\":\"1\",\"name\":\"foo\"}\n{\"id\":\"2\",\"name\":\"bar\"}\n",
+			records:  2,
+		},
+		{
+			name:     "lines to netstring",
+			input:    "hello\nworld\n",
+			framing:  `"codec": "lines"`,
+			codec:    "netstring",
+			expected: "5:hello,5:world,",
+			records:  2,
+		},
+		{
+			name:     "netstring to length prefixed",
+			input:    "2:hi,0:,",
+			framing:  `"codec": "netstring"`,
+			codec:    "length_prefixed_uint32_be",
+			expected: "\x00\x00\x00\x02hi\x00\x00\x00\x00",
+			records:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			srcFile := filepath.Join(tempDir, "source")
+			destFile := filepath.Join(tempDir, "dest")
+			if err := os.WriteFile(srcFile, []byte(test.input), 0o644); err != nil {
+				t.Fatal("Failed to create test file:", err)
+			}
+
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "reflow",
+				"path": "` + srcFile + `",
+				"destination_path": "` + destFile + `",
+				"output_codec": "` + test.codec + `",
+				` + test.framing + `
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			content, err := os.ReadFile(destFile)
+			if err != nil {
+				t.Fatal("Failed to read destination file:", err)
+			}
+			if string(content) != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, content)
+			}
+			if written, _ := result[0].MetaGetMut("file_records_written"); written != test.records {
+				t.Errorf("Expected %d records written, got %v", test.records, written)
+			}
+		})
+	}
+}
+
+func TestFileProcessorOperationDuration(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "timed.txt")
+	if err := os.WriteFile(testFile, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"codec": "lines"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(result))
+	}
+	for i, m := range result {
+		duration, ok := m.MetaGetMut("file_operation_duration_ms")
+		if !ok {
+			t.Fatalf("Expected message %d to have file_operation_duration_ms", i)
+		}
+		if d, isInt := duration.(int64); !isInt || d < 0 {
+			t.Errorf("Expected a non-negative integer duration, got %v", duration)
+		}
+	}
+}
+
+func TestFileProcessorReadSplitRegex(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "records.txt")
+	if err := os.WriteFile(testFile, []byte("first;second|third\n;;fourth"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"split_regex": "\\n?[;|]"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	expected := []string{"first", "second", "third", "", "fourth"}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d records, got %d", len(expected), len(result))
+	}
+	for i, e := range expected {
+		if content, _ := result[i].AsBytes(); string(content) != e {
+			t.Errorf("Expected record %d to be '%s', got '%s'", i, e, content)
+		}
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"split_regex": ",*"
+	}`); err == nil {
+		t.Error("Expected a split_regex matching an empty string to be rejected")
+	}
+}
+
+func TestFileProcessorReadSplitRegexChunked(t *testing.T) {
+	tempDir := t.TempDir()
+	longRecord := strings.Repeat("a", 4<<20)
+
+	for _, test := range []struct {
+		name     string
+		regex    string
+		content  string
+		chunk    int
+		expected []string
+	}{
+		{name: "long record", regex: `\r?\n---\r?\n`, content: longRecord + "\n---\ntail", chunk: 64, expected: []string{longRecord, "tail"}},
+		{name: "spanning reads", regex: `\r?\n---\r?\n`, content: "a\n---\nb\r\n---\r\nc", chunk: 1, expected: []string{"a", "b", "c"}},
+		{name: "unbounded", regex: `\n+`, content: "a\n\n\nb\nc", chunk: 1, expected: []string{"a", "b", "c"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, "records.txt")
+			if err := os.WriteFile(testFile, []byte(test.content), 0o644); err != nil {
+				t.Fatal("Failed to create test file:", err)
+			}
+
+			testFS := &fileProcessorTestFS{FS: ifs.OS()}
+			testFS.open = func(name string) (fs.File, error) {
+				f, err := testFS.FS.Open(name)
+				if err != nil {
+					return f, err
+				}
+				return &chunkedFile{File: f, chunk: test.chunk}, nil
+			}
+			proc, err := newFileProcessorFromConfigWithFS(`{
+				"operation": "read",
+				"path": "`+testFile+`",
+				"split_regex": "`+strings.ReplaceAll(test.regex, `\`, `\\`)+`"
+			}`, testFS)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if len(result) != len(test.expected) {
+				t.Fatalf("Expected %d records, got %d", len(test.expected), len(result))
+			}
+			for i, e := range test.expected {
+				if content, _ := result[i].AsBytes(); string(content) != e {
+					t.Errorf("Expected record %d of length %d, got %d", i, len(e), len(content))
+				}
+			}
+		})
+	}
+
+	for expr, expected := range map[string]int{
+		`[;|]`:          1,
+		`\r?\n---\r?\n`: 7,
+		`é{2,3}`:        6,
+		`(?i)k`:         4,
+		`\n+`:           -1,
+		`\bend`:         -1,
+	} {
+		if n := regexpMaxLen(regexp.MustCompile(expr)); n != expected {
+			t.Errorf("Expected the longest match of '%s' to be %d, got %d", expr, expected, n)
+		}
+	}
+}
+
+func TestFileProcessorRestore(t *testing.T) {
+	tempDir := t.TempDir()
+	withSidecar := filepath.Join(tempDir, "with.bin")
+	withoutSidecar := filepath.Join(tempDir, "without.bin")
+	for _, path := range []string{withSidecar, withoutSidecar} {
+		if err := os.WriteFile(path, []byte("body"), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+	}
+	if err := os.WriteFile(withSidecar+".meta.json", []byte(`{"kafka_key":"abc","attempt":3}`), 0o644); err != nil {
+		t.Fatal("Failed to create sidecar:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "restore",
+		"path": "${! content() }"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte(withSidecar)))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if content, _ := result[0].AsBytes(); string(content) != "body" {
+		t.Errorf("Expected restored content 'body', got '%s'", content)
+	}
+	if key, _ := result[0].MetaGet("kafka_key"); key != "abc" {
+		t.Errorf("Expected kafka_key metadata 'abc', got '%s'", key)
+	}
+	if attempt, _ := result[0].MetaGetMut("attempt"); attempt != float64(3) {
+		t.Errorf("Expected typed attempt metadata 3, got %v", attempt)
+	}
+
+	result, err = proc.Process(context.Background(), service.NewMessage([]byte(withoutSidecar)))
+	if err != nil {
+		t.Fatal("Expected missing sidecar to be tolerated:", err)
+	}
+	if content, _ := result[0].AsBytes(); string(content) != "body" {
+		t.Errorf("Expected restored content 'body', got '%s'", content)
+	}
+}
+
+func TestFileProcessorBOM(t *testing.T) {
+	tempDir := t.TempDir()
+	readFile := filepath.Join(tempDir, "bom.txt")
+	if err := os.WriteFile(readFile, []byte("\xEF\xBB\xBFfirst\nsecond"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	for bom, expected := range map[string][]string{
+		"preserve": {"\xEF\xBB\xBFfirst", "second"},
+		"strip":    {"first", "second"},
+	} {
+		proc, err := newFileProcessorFromConfig(`{
+			"operation": "read",
+			"path": "` + readFile + `",
+			"codec": "lines",
+			"bom": "` + bom + `"
+		}`)
+		if err != nil {
+			t.Fatal("Failed to create processor:", err)
+		}
+		result, err := proc.Process(context.Background(), service.NewMessage(nil))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		if len(result) != len(expected) {
+			t.Fatalf("Expected %d records with bom %s, got %d", len(expected), bom, len(result))
+		}
+		for i, e := range expected {
+			if content, _ := result[i].AsBytes(); string(content) != e {
+				t.Errorf("Expected record %d with bom %s to be %q, got %q", i, bom, e, content)
+			}
+		}
+	}
+
+	writeFile := filepath.Join(tempDir, "out.txt")
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + writeFile + `",
+		"bom": "add"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	for _, input := range []string{"content", "\xEF\xBB\xBFcontent"} {
+		if _, err := proc.Process(context.Background(), service.NewMessage([]byte(input))); err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		content, err := os.ReadFile(writeFile)
+		if err != nil {
+			t.Fatal("Failed to read written file:", err)
+		}
+		if string(content) != "\xEF\xBB\xBFcontent" {
+			t.Errorf("Expected %q to be written with a single BOM, got %q", input, content)
+		}
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + writeFile + `",
+		"bom": "strip"
+	}`); err == nil {
+		t.Error("Expected bom strip to be rejected for the write operation")
+	}
+}
+
+func TestFileProcessorWatchSize(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "app.log")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "watch_size",
+		"path": "` + testFile + `",
+		"size_threshold": "10B",
+		"poll_interval": "10ms"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	// Nothing is emitted while the file is missing or below the threshold
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := proc.Process(ctx, service.NewMessage(nil)); !errors.Is(err, component.ErrTimeout) {
+		t.Errorf("Expected a missing file to time out, got %v", err)
+	}
+
+	if err := os.WriteFile(testFile, []byte("short"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.WriteFile(testFile, []byte("short and now long"), 0o644)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := proc.Process(ctx, service.NewMessage([]byte("trigger")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+	if size, _ := result[0].MetaGetMut("file_size"); size != int64(18) {
+		t.Errorf("Expected file_size to be 18, got %v", size)
+	}
+	if content, _ := result[0].AsBytes(); string(content) != "trigger" {
+		t.Errorf("Expected message content to be unchanged, got '%s'", content)
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "watch_size",
+		"path": "` + testFile + `"
+	}`); err == nil {
+		t.Error("Expected watch_size without size_threshold to be rejected")
+	}
+}
+
+func TestFileProcessorWriteValidate(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "doc")
+
+	tests := []struct {
+		name    string
+		config  string
+		content string
+		valid   bool
+	}{
+		{name: "json valid", config: `"validate": "json"`, content: `{"id":1}`, valid: true},
+		{name: "json invalid", config: `"validate": "json"`, content: `{"id":`, valid: false},
+		{name: "xml valid", config: `"validate": "xml"`, content: `<doc><id>1</id></doc>`, valid: true},
+		{name: "xml unclosed", config: `"validate": "xml"`, content: `<doc><id>1</id>`, valid: false},
+		{name: "xml no root", config: `"validate": "xml"`, content: `just text`, valid: false},
+		{name: "check passes", config: `"validate": "json", "validate_check": "this.exists(\"id\")"`, content: `{"id":1}`, valid: true},
+		{name: "check fails", config: `"validate": "json", "validate_check": "this.exists(\"id\")"`, content: `{"name":"foo"}`, valid: false},
+	}
+
+	for _, operation := range []string{"write", "append"} {
+		for _, test := range tests {
+			t.Run(operation+" "+test.name, func(t *testing.T) {
+				if err := os.WriteFile(testFile, []byte("original"), 0o644); err != nil {
+					t.Fatal("Failed to create test file:", err)
+				}
+
+				proc, err := newFileProcessorFromConfig(`{
+					"operation": "` + operation + `",
+					"path": "` + testFile + `",
+					` + test.config + `
+				}`)
+				if err != nil {
+					t.Fatal("Failed to create processor:", err)
+				}
+
+				_, err = proc.Process(context.Background(), service.NewMessage([]byte(test.content)))
+				content, _ := os.ReadFile(testFile)
+				if test.valid {
+					if err != nil {
+						t.Fatal("Process failed:", err)
+					}
+					expected := test.content
+					if operation == "append" {
+						expected = "original" + expected
+					}
+					if string(content) != expected {
+						t.Errorf("Expected file content '%s', got '%s'", expected, content)
+					}
+				} else {
+					if err == nil {
+						t.Fatal("Expected invalid content to be rejected")
+					}
+					if string(content) != "original" {
+						t.Errorf("Expected file to be left untouched, got '%s'", content)
+					}
+				}
+			})
+		}
+	}
+
+	for field, conf := range map[string]string{
+		"validate":       `"validate": "json"`,
+		"validate_check": `"validate_check": "this.exists(\"id\")"`,
+	} {
+		if _, err := newFileProcessorFromConfig(`{
+			"operation": "stat",
+			"path": "` + testFile + `",
+			` + conf + `
+		}`); err == nil || !strings.Contains(err.Error(), field+" is only supported") {
+			t.Errorf("Expected %v to be rejected for the stat operation, got %v", field, err)
+		}
+	}
+}
+
+func TestFileProcessorCounter(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "seq")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "counter",
+		"path": "` + testFile + `",
+		"step": 5
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	// Concurrent increments must not be lost
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := proc.Process(context.Background(), service.NewMessage(nil)); err != nil {
+				t.Error("Process failed:", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte("id")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if value, _ := result[0].MetaGetMut("file_counter_value"); value != int64(55) {
+		t.Errorf("Expected file_counter_value to be 55, got %v", value)
+	}
+	if content, _ := result[0].AsBytes(); string(content) != "id" {
+		t.Errorf("Expected message content to be unchanged, got '%s'", content)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "55\n" {
+		t.Errorf("Expected counter file to hold 55, got '%s'", content)
+	}
+
+	if err := os.WriteFile(testFile, []byte("not a number"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+	if _, err := proc.Process(context.Background(), service.NewMessage(nil)); err == nil {
+		t.Error("Expected a corrupt counter file to fail")
+	}
+}
+
+func TestFileProcessorBulkWrite(t *testing.T) {
+	tempDir := t.TempDir()
+	primary := filepath.Join(tempDir, "primary", "doc.txt")
+	replica := filepath.Join(tempDir, "replica", "doc.txt")
+	blocked := filepath.Join(tempDir, "blocked")
+	if err := os.MkdirAll(blocked, 0o755); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(fmt.Sprintf(`
+operation: write
+paths: 'root = ["%s", "%s"]'
+`, primary, replica))
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte("content")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	for _, path := range []string{primary, replica} {
+		if content, _ := os.ReadFile(path); string(content) != "content" {
+			t.Errorf("Expected '%s' to hold 'content', got '%s'", path, content)
+		}
+	}
+	if results, _ := result[0].MetaGetMut("file_write_results"); !reflect.DeepEqual(results, map[string]any{primary: true, replica: true}) {
+		t.Errorf("Unexpected file_write_results: %v", results)
+	}
+
+	// A destination that cannot be written is reported in metadata
+	proc, err = newFileProcessorFromConfig(fmt.Sprintf(`
+operation: write
+paths: 'root = ["%s", "%s"]'
+`, primary, blocked))
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if result, err = proc.Process(context.Background(), service.NewMessage([]byte("partial"))); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if content, _ := os.ReadFile(primary); string(content) != "partial" {
+		t.Errorf("Expected primary to be written, got '%s'", content)
+	}
+	if results, _ := result[0].MetaGetMut("file_write_results"); !reflect.DeepEqual(results, map[string]any{primary: true, blocked: false}) {
+		t.Errorf("Unexpected file_write_results: %v", results)
+	}
+	if failures, _ := result[0].MetaGetMut("file_write_errors"); len(failures.(map[string]any)) != 1 {
+		t.Errorf("Expected one entry in file_write_errors, got %v", failures)
+	}
+
+	// With all_or_nothing no destination is modified
+	proc, err = newFileProcessorFromConfig(fmt.Sprintf(`
+operation: write
+paths: 'root = ["%s", "%s", "%s"]'
+all_or_nothing: true
+`, primary, replica, blocked))
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("none"))); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if content, _ := os.ReadFile(primary); string(content) != "partial" {
+		t.Errorf("Expected primary to be untouched, got '%s'", content)
+	}
+	if content, _ := os.ReadFile(replica); string(content) != "content" {
+		t.Errorf("Expected replica to be untouched, got '%s'", content)
+	}
+	for _, dir := range []string{filepath.Dir(primary), filepath.Dir(replica)} {
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("Expected temporary files in '%s' to be removed, found %d entries", dir, len(entries))
+		}
+	}
+
+	// With fail_fast the paths after a failure are left untouched
+	proc, err = newFileProcessorFromConfig(fmt.Sprintf(`
+operation: write
+paths: 'root = ["%s", "%s"]'
+error_mode: fail_fast
+`, blocked, primary))
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("fast"))); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if content, _ := os.ReadFile(primary); string(content) != "partial" {
+		t.Errorf("Expected primary to be untouched, got '%s'", content)
+	}
+
+	if _, err := newFileProcessorFromConfig(fmt.Sprintf(`
+operation: write
+paths: 'root = ["%s"]'
+skip_unchanged: true
+`, primary)); err == nil {
+		t.Error("Expected skip_unchanged to be rejected with paths")
+	}
+}
+
+// stallingScanner is a scanner that ignores cancellation and blocks until
+// release is closed.
+type stallingScanner struct {
+	release <-chan struct{}
+	closed  chan struct{}
+}
+
+func (s *stallingScanner) Create(rdr io.ReadCloser, aFn service.AckFunc, details *service.ScannerSourceDetails) (service.BatchScanner, error) {
+	return service.AutoAggregateBatchScannerAcks(s, aFn), nil
+}
+
+func (s *stallingScanner) NextBatch(ctx context.Context) (service.MessageBatch, error) {
+	<-s.release
+	return nil, io.EOF
+}
+
+func (s *stallingScanner) Close(ctx context.Context) error {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	return nil
+}
+
+func TestFileProcessorReadScannerTimeout(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "stuck.txt")
+	if err := os.WriteFile(testFile, []byte("no delimiter"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	release := make(chan struct{})
+	stalling := &stallingScanner{release: release, closed: make(chan struct{})}
+
+	env := service.NewEnvironment()
+	if err := env.RegisterBatchScannerCreator("stalling", service.NewConfigSpec(), func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchScannerCreator, error) {
+		return stalling, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pConf, err := fileProcessorSpec().ParseYAML(`
+operation: read
+path: `+testFile+`
+scanner:
+  stalling: {}
+scanner_timeout: 50ms
+`, env)
+	if err != nil {
+		t.Fatal("Failed to parse config:", err)
+	}
+	proc, err := fileProcessorFromParsed(pConf, service.MockResources())
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	start := time.Now()
+	_, err = proc.Process(context.Background(), service.NewMessage(nil))
+	if err == nil || !strings.Contains(err.Error(), "did not produce a batch within 50ms") {
+		t.Fatalf("Expected a scanner timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the read to be abandoned promptly, took %v", elapsed)
+	}
+
+	// The abandoned scanner is closed once its pending call returns
+	close(release)
+	select {
+	case <-stalling.closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the abandoned scanner to be closed")
+	}
+}
+
+func TestFileProcessorPreserveTree(t *testing.T) {
+	for _, op := range []string{"move", "rename"} {
+		t.Run(op, func(t *testing.T) {
+			tempDir := t.TempDir()
+			srcBase := filepath.Join(tempDir, "src")
+			destDir := filepath.Join(tempDir, "dest")
+			srcFile := filepath.Join(srcBase, "a", "b", "f.txt")
+			if err := os.MkdirAll(filepath.Dir(srcFile), 0o755); err != nil {
+				t.Fatal("Failed to create directory:", err)
+			}
+			if err := os.WriteFile(srcFile, []byte("nested"), 0o644); err != nil {
+				t.Fatal("Failed to create test file:", err)
+			}
+
+			proc, err := newFileProcessorFromConfig(fmt.Sprintf(`
+operation: %s
+path: ${! content() }
+destination_path: %s
+preserve_tree: true
+source_base: %s
+`, op, destDir, srcBase))
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			if _, err := proc.Process(context.Background(), service.NewMessage([]byte(srcFile))); err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if content, err := os.ReadFile(filepath.Join(destDir, "a", "b", "f.txt")); err != nil || string(content) != "nested" {
+				t.Errorf("Expected file to be moved beneath its relative directory, got '%s': %v", content, err)
+			}
+			if _, err := os.Stat(srcFile); !os.IsNotExist(err) {
+				t.Error("Expected source file to be removed")
+			}
+
+			outside := filepath.Join(tempDir, "outside.txt")
+			if err := os.WriteFile(outside, []byte("outside"), 0o644); err != nil {
+				t.Fatal("Failed to create test file:", err)
+			}
+			if _, err := proc.Process(context.Background(), service.NewMessage([]byte(outside))); err == nil {
+				t.Error("Expected a path outside of source_base to be rejected")
+			}
+		})
+	}
+
+	if _, err := newFileProcessorFromConfig(`
+operation: move
+path: /tmp/a
+destination_path: /tmp/b
+preserve_tree: true
+`); err == nil {
+		t.Error("Expected preserve_tree without source_base to be rejected")
+	}
+}
+
+func TestFileProcessorLengthPrefixFraming(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for codec, prefix := range map[string][]byte{
+		"length_prefixed_uint16_be": {0x00, 0x05},
+		"length_prefixed_uint32_be": {0x00, 0x00, 0x00, 0x05},
+		"length_prefixed_uint64_be": {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05},
+	} {
+		t.Run(codec, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, codec+".bin")
+
+			writer, err := newFileProcessorFromConfig(`{
+				"operation": "write",
+				"path": "` + testFile + `",
+				"framing": "` + codec + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+			if _, err := writer.Process(context.Background(), service.NewMessage([]byte("hello"))); err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			content, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatal("Failed to read written file:", err)
+			}
+			if expected := append(slices.Clone(prefix), "hello"...); !bytes.Equal(content, expected) {
+				t.Errorf("Expected %x, got %x", expected, content)
+			}
+
+			// Append a second record and read both back with the same codec
+			appender, err := newFileProcessorFromConfig(`{
+				"operation": "append",
+				"path": "` + testFile + `",
+				"framing": "` + codec + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+			if _, err := appender.Process(context.Background(), service.NewMessage([]byte("world"))); err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			reader, err := newFileProcessorFromConfig(`{
+				"operation": "read",
+				"path": "` + testFile + `",
+				"codec": "` + codec + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+			result, err := reader.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if len(result) != 2 {
+				t.Fatalf("Expected 2 records, got %d", len(result))
+			}
+			for i, e := range []string{"hello", "world"} {
+				if content, _ := result[i].AsBytes(); string(content) != e {
+					t.Errorf("Expected record %d to be '%s', got '%s'", i, e, content)
+				}
+			}
+		})
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + filepath.Join(tempDir, "x") + `",
+		"framing": "length_prefixed_uint16_be",
+		"ensure_trailing_newline": true
+	}`); err == nil {
+		t.Error("Expected framing with ensure_trailing_newline to be rejected")
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + filepath.Join(tempDir, "x") + `",
+		"codec": "lines",
+		"framing": "length_prefixed_uint16_be"
+	}`); err == nil || !strings.Contains(err.Error(), "framing is only supported") {
+		t.Errorf("Expected framing to be rejected for the read operation, got %v", err)
+	}
+}
+
+func TestFileProcessorReadCollapse(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "lines.txt")
+	if err := os.WriteFile(testFile, []byte("first\r\nsecond\nthird\n"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"scanner": { "lines": {} },
+		"collapse": true
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+	if content, _ := result[0].AsBytes(); string(content) != "first\nsecond\nthird" {
+		t.Errorf("Expected records to be joined, got %q", content)
+	}
+	if count, _ := result[0].MetaGetMut("file_record_count"); count != int64(3) {
+		t.Errorf("Expected file_record_count to be 3, got %v", count)
+	}
+	if path, _ := result[0].MetaGetMut("file_path"); path != testFile {
+		t.Errorf("Expected file_path to be '%s', got %v", testFile, path)
+	}
+}
+
+func TestFileProcessorStaticPathValidation(t *testing.T) {
+	for name, conf := range map[string]string{
+		"empty path":           `{"operation": "stat", "path": "  "}`,
+		"null byte path":       `{"operation": "stat", "path": "/tmp/a\u0000b"}`,
+		"null byte dest":       `{"operation": "rename", "path": "/tmp/a", "destination_path": "/tmp/\u0000"}`,
+		"null byte sourcebase": `{"operation": "move", "path": "/tmp/a/b", "destination_path": "/tmp/c", "preserve_tree": true, "source_base": "/tmp/\u0000"}`,
+	} {
+		if _, err := newFileProcessorFromConfig(conf); err == nil {
+			t.Errorf("Expected %s to be rejected at config time", name)
+		}
+	}
+
+	// Interpolated paths are only resolved per message
+	if _, err := newFileProcessorFromConfig(`{"operation": "stat", "path": "${! content() }"}`); err != nil {
+		t.Errorf("Expected an interpolated path to be accepted: %v", err)
+	}
+}
+
+func TestFileProcessorSwap(t *testing.T) {
+	tempDir := t.TempDir()
+	current := filepath.Join(tempDir, "current")
+	next := filepath.Join(tempDir, "next")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "swap",
+		"path": "` + current + `",
+		"destination_path": "` + next + `"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	if _, err := proc.Process(context.Background(), service.NewMessage(nil)); err == nil {
+		t.Error("Expected swapping missing files to fail")
+	}
+
+	for path, content := range map[string]string{current: "v1", next: "v2"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+	}
+	assertContents := func(expCurrent, expNext string) {
+		t.Helper()
+		if content, _ := os.ReadFile(current); string(content) != expCurrent {
+			t.Errorf("Expected current to hold '%s', got '%s'", expCurrent, content)
+		}
+		if content, _ := os.ReadFile(next); string(content) != expNext {
+			t.Errorf("Expected next to hold '%s', got '%s'", expNext, content)
+		}
+		if entries, _ := os.ReadDir(tempDir); len(entries) != 2 {
+			t.Errorf("Expected no temporary files to remain, found %d entries", len(entries))
+		}
+	}
+
+	if _, err := proc.Process(context.Background(), service.NewMessage(nil)); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	assertContents("v2", "v1")
+
+	// The fallback used where an atomic exchange is unsupported
+	if err := proc.swapViaTempFile(current, next); err != nil {
+		t.Fatal("Swap failed:", err)
+	}
+	assertContents("v1", "v2")
+
+	// A failed rename during the fallback leaves both files in place
+	var renames int
+	proc.rename = func(oldpath, newpath string) error {
+		if renames++; renames == 2 {
+			return errors.New("simulated failure")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	if err := proc.swapViaTempFile(current, next); err == nil {
+		t.Fatal("Expected the swap to fail")
+	}
+	assertContents("v1", "v2")
+}
+
+func TestFileProcessorSwapResourceFS(t *testing.T) {
+	root := t.TempDir()
+	if _, err := newFileProcessorFromConfigWithFS(`{
+		"operation": "swap",
+		"path": "/data/current",
+		"destination_path": "/data/next"
+	}`, &rootedTestFS{root: root}); err == nil || !strings.Contains(err.Error(), "not supported by custom filesystems") {
+		t.Errorf("Expected swap to be rejected for a custom filesystem, got %v", err)
+	}
+}
+
+func TestFileProcessorReadOnEmpty(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "empty.txt")
+	if err := os.WriteFile(testFile, nil, 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	newProc := func(onEmpty string) *fileProcessor {
+		t.Helper()
+		proc, err := newFileProcessorFromConfig(`{
+			"operation": "read",
+			"path": "` + testFile + `",
+			"codec": "lines",
+			"on_empty": "` + onEmpty + `"
+		}`)
+		if err != nil {
+			t.Fatal("Failed to create processor:", err)
+		}
+		return proc
+	}
+
+	result, err := newProc("metadata").Process(context.Background(), service.NewMessage([]byte("input")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(result))
+	}
+	if size, _ := result[0].MetaGetMut("file_size"); size != int64(0) {
+		t.Errorf("Expected file_size to be 0, got %v", size)
+	}
+
+	if result, err = newProc("skip").Process(context.Background(), service.NewMessage([]byte("input"))); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no messages, got %d", len(result))
+	}
+
+	if _, err := newProc("error").Process(context.Background(), service.NewMessage([]byte("input"))); err == nil {
+		t.Error("Expected an empty file to fail")
+	}
+}
+
+func TestFileProcessorBulkWorkers(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var names []string
+	for i := range 20 {
+		names = append(names, fmt.Sprintf("f%02d.txt", i))
+	}
+
+	writer, err := newFileProcessorFromConfig(fmt.Sprintf(`
+operation: write
+paths: 'root = this.files.map_each(f -> "%s/" + f)'
+workers: 4
+`, tempDir))
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	deleter, err := newFileProcessorFromConfig(fmt.Sprintf(`
+operation: delete
+paths: 'root = this.files.map_each(f -> "%s/" + f)'
+workers: 4
+`, tempDir))
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	doc := fmt.Sprintf(`{"files":["%s"]}`, strings.Join(names, `","`))
+	result, err := writer.Process(context.Background(), service.NewMessage([]byte(doc)))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if results, _ := result[0].MetaGetMut("file_write_results"); len(results.(map[string]any)) != len(names) {
+		t.Errorf("Expected a write result for each path, got %v", results)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != len(names) {
+		t.Errorf("Expected %d files to be written, found %d", len(names), len(entries))
+	}
+
+	// Delete every file along with one that does not exist
+	doc = fmt.Sprintf(`{"files":["%s","missing.txt"]}`, strings.Join(names, `","`))
+	if result, err = deleter.Process(context.Background(), service.NewMessage([]byte(doc))); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	results, _ := result[0].MetaGetMut("file_delete_results")
+	for _, name := range names {
+		if results.(map[string]any)[filepath.Join(tempDir, name)] != true {
+			t.Errorf("Expected '%s' to be deleted", name)
+		}
+	}
+	if failures, _ := result[0].MetaGetMut("file_delete_errors"); len(failures.(map[string]any)) != 1 {
+		t.Errorf("Expected one delete error, got %v", failures)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("Expected all files to be deleted, found %d", len(entries))
+	}
+
+	// Cancellation stops the pool
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := deleter.Process(ctx, service.NewMessage([]byte(doc))); !errors.Is(err, component.ErrTimeout) {
+		t.Errorf("Expected a cancelled delete to time out, got %v", err)
+	}
+}
+
+func TestFileProcessorReadByteOffsets(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "records.txt")
+	if err := os.WriteFile(testFile, []byte("ab\r\ncde\n\nf"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	tests := []struct {
+		name     string
+		framing  string
+		expected map[string]int64
+	}{
+		{name: "lines", framing: `"codec": "lines"`, expected: map[string]int64{"ab": 0, "cde": 4, "": 8, "f": 9}},
+		{name: "split_regex", framing: `"split_regex": "\\r?\\n"`, expected: map[string]int64{"ab": 0, "cde": 4, "": 8, "f": 9}},
+		{name: "byte_chunk_size", framing: `"byte_chunk_size": "4B"`, expected: map[string]int64{"ab\r\n": 0, "cde\n": 4, "\nf": 8}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "read",
+				"path": "` + testFile + `",
+				"byte_offsets": true,
+				` + test.framing + `
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			if len(result) != len(test.expected) {
+				t.Fatalf("Expected %d records, got %d", len(test.expected), len(result))
+			}
+			for _, msg := range result {
+				content, _ := msg.AsBytes()
+				if offset, _ := msg.MetaGetMut("file_byte_offset"); offset != test.expected[string(content)] {
+					t.Errorf("Expected record %q to begin at %d, got %v", content, test.expected[string(content)], offset)
+				}
+			}
+		})
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"byte_offsets": true,
+		"scanner": { "lines": {} }
+	}`); err == nil {
+		t.Error("Expected byte_offsets with a scanner to be rejected")
+	}
+}
+
+func TestFileProcessorWriteContentAddressed(t *testing.T) {
+	tempDir := t.TempDir()
+	objectsDir := filepath.Join(tempDir, "objects")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + objectsDir + `",
+		"content_addressed": true
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	checksum := sha256.Sum256([]byte("hello world"))
+	expectedPath := filepath.Join(objectsDir, hex.EncodeToString(checksum[:]))
+
+	for i, expectedSkipped := range []string{"false", "true"} {
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte("hello world")))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		if path, _ := result[0].MetaGet("file_path"); path != expectedPath {
+			t.Errorf("Expected path '%s', got '%s'", expectedPath, path)
+		}
+		if skipped, _ := result[0].MetaGet("file_write_skipped"); skipped != expectedSkipped {
+			t.Errorf("Write %d: expected file_write_skipped '%s', got '%s'", i, expectedSkipped, skipped)
+		}
+	}
+
+	content, err := os.ReadFile(expectedPath)
+	if err != nil {
+		t.Fatal("Failed to read written file:", err)
+	}
+	if string(content) != "hello world" {
+		t.Errorf("Expected 'hello world', got '%s'", content)
+	}
+
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		t.Fatal("Failed to read directory:", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected a single object, got %d", len(entries))
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + objectsDir + `",
+		"content_addressed": true,
+		"skip_unchanged": true
+	}`); err == nil {
+		t.Error("Expected content_addressed with skip_unchanged to be rejected")
+	}
+}
+
+func TestFileProcessorWriteFallbackPath(t *testing.T) {
+	tempDir := t.TempDir()
+	primaryDir := filepath.Join(tempDir, "primary")
+	fallbackDir := filepath.Join(tempDir, "fallback")
+
+	tests := []struct {
+		name         string
+		failure      error
+		expectErr    bool
+		expectedUsed string
+	}{
+		{name: "disk full", failure: syscall.ENOSPC, expectedUsed: "true"},
+		{name: "permission denied", failure: fs.ErrPermission, expectedUsed: "true"},
+		{name: "unclassified error", failure: errors.New("boom"), expectErr: true},
+		{name: "primary succeeds", expectedUsed: "false"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if errors.Is(test.failure, syscall.ENOSPC) && runtime.GOOS == "windows" {
+				t.Skip("disk full errors are simulated using ENOSPC, which is not reported on Windows")
+			}
+
+			failingFS := &fileProcessorTestFS{
+				FS: ifs.OS(),
+				openFile: func(name string, flag int, perm fs.FileMode) (fs.File, error) {
+					if test.failure != nil && strings.HasPrefix(name, primaryDir) {
+						return nil, &fs.PathError{Op: "open", Path: name, Err: test.failure}
+					}
+					return os.OpenFile(name, flag, perm)
+				},
+			}
+
+			proc, err := newFileProcessorFromConfigWithFS(`{
+				"operation": "write",
+				"path": "`+filepath.Join(primaryDir, "${! @name }.txt")+`",
+				"fallback_path": "`+filepath.Join(fallbackDir, "${! @name }.txt")+`"
+			}`, failingFS)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			msg := service.NewMessage([]byte("hello"))
+			msg.MetaSetMut("name", test.name)
+			result, err := proc.Process(context.Background(), msg)
+			if test.expectErr {
+				if err == nil {
+					t.Fatal("Expected the write to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			expectedPath := filepath.Join(primaryDir, test.name+".txt")
+			if test.expectedUsed == "true" {
+				expectedPath = filepath.Join(fallbackDir, test.name+".txt")
+			}
+			if used, _ := result[0].MetaGet("file_fallback_used"); used != test.expectedUsed {
+				t.Errorf("Expected file_fallback_used '%s', got '%s'", test.expectedUsed, used)
+			}
+			if path, _ := result[0].MetaGet("file_path"); path != expectedPath {
+				t.Errorf("Expected file_path '%s', got '%s'", expectedPath, path)
+			}
+			if content, err := os.ReadFile(expectedPath); err != nil || string(content) != "hello" {
+				t.Errorf("Expected '%s' to contain 'hello', got '%s' (%v)", expectedPath, content, err)
+			}
+		})
+	}
+}
+
+func TestFileProcessorWriteSocket(t *testing.T) {
+	// Socket paths are limited in length, so avoid the long test temp dir
+	socketDir, err := os.MkdirTemp("", "bento")
+	if err != nil {
+		t.Fatal("Failed to create temp dir:", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+	socketPath := filepath.Join(socketDir, "agent.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skip("Unix domain sockets are not supported:", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + socketPath + `",
+		"socket": true
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("hello agent"))); err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	select {
+	case data := <-received:
+		if string(data) != "hello agent" {
+			t.Errorf("Expected 'hello agent', got '%s'", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for socket data")
+	}
+
+	fileInfo, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal("Failed to stat socket:", err)
+	}
+	if fileInfo.Mode()&fs.ModeSocket == 0 {
+		t.Error("Expected the socket not to be replaced by a regular file")
+	}
+
+	// A socket nobody is listening on reports a connection error
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("hello"))); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("Expected a connection error, got: %v", err)
+	}
+}
+
+func TestFileProcessorReadPartMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "parts.txt")
+	if err := os.WriteFile(testFile, []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"codec": "lines",
+		"part_metadata": true
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(result))
+	}
+	for i, msg := range result {
+		if index, _ := msg.MetaGetMut("file_part_index"); index != int64(i) {
+			t.Errorf("Expected file_part_index %d, got %v", i, index)
+		}
+		if count, _ := msg.MetaGetMut("file_part_count"); count != int64(3) {
+			t.Errorf("Expected file_part_count 3, got %v", count)
+		}
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + testFile + `",
+		"codec": "lines",
+		"part_metadata": true,
+		"collapse": true
+	}`); err == nil {
+		t.Error("Expected part_metadata with collapse to be rejected")
+	}
+}
+
+func TestFileProcessorDelta(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		existing string
+		missing  bool
+		content  string
+		added    int64
+		removed  int64
+	}{
+		{name: "unchanged", existing: "a\nb\nc\n", content: "a\nb\nc\n"},
+		{name: "missing file", missing: true, content: "a\nb\n", added: 2},
+		{name: "modified line", existing: "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n", content: "a\nB\nc\nd\ne\nf\ng\nh\ni\nJ\n", added: 2, removed: 2},
+		{name: "emptied", existing: "a\nb\n", content: "", removed: 2},
+		{name: "no trailing newline", existing: "a\nb", content: "a\nb\nc", added: 2, removed: 1},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, fmt.Sprintf("delta%d.txt", i))
+			if !test.missing {
+				if err := os.WriteFile(testFile, []byte(test.existing), 0o644); err != nil {
+					t.Fatal("Failed to create test file:", err)
+				}
+			}
+
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "delta",
+				"path": "` + testFile + `"
+			}`)
+			if err != nil {
+				t.Fatal("Failed to create processor:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage([]byte(test.content)))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+			diff, _ := result[0].AsBytes()
+
+			if changed, _ := result[0].MetaGetMut("file_delta_changed"); changed != (test.added+test.removed > 0) {
+				t.Errorf("Unexpected file_delta_changed: %v", changed)
+			}
+			if added, _ := result[0].MetaGetMut("file_delta_lines_added"); added != test.added {
+				t.Errorf("Expected %d lines added, got %v", test.added, added)
+			}
+			if removed, _ := result[0].MetaGetMut("file_delta_lines_removed"); removed != test.removed {
+				t.Errorf("Expected %d lines removed, got %v", test.removed, removed)
+			}
+			if len(diff) == 0 {
+				if test.added+test.removed > 0 {
+					t.Fatal("Expected a non-empty diff")
+				}
+				return
+			}
+
+			// The diff must reproduce the content when applied to the file
+			hunks, err := parseUnifiedDiff(diff)
+			if err != nil {
+				t.Fatalf("Failed to parse diff: %v\n%s", err, diff)
+			}
+			patched, _, failed, err := applyUnifiedDiff([]byte(test.existing), hunks, false)
+			if err != nil || failed > 0 {
+				t.Fatalf("Failed to apply diff: %v\n%s", err, diff)
+			}
+			if string(patched) != test.content {
+				t.Errorf("Expected patched content %q, got %q\n%s", test.content, patched, diff)
+			}
+		})
+	}
+
+	t.Run("destination path", func(t *testing.T) {
+		testFile := filepath.Join(tempDir, "dest.txt")
+		destFile := filepath.Join(tempDir, "dest.diff")
+		if err := os.WriteFile(testFile, []byte("old\n"), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+
+		proc, err := newFileProcessorFromConfig(`{
+			"operation": "delta",
+			"path": "` + testFile + `",
+			"destination_path": "` + destFile + `"
+		}`)
+		if err != nil {
+			t.Fatal("Failed to create processor:", err)
+		}
+
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte("new\n")))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		if content, _ := result[0].AsBytes(); string(content) != "new\n" {
+			t.Errorf("Expected message content to be unchanged, got %q", content)
+		}
+
+		expected := "--- " + testFile + "\n+++ " + testFile + "\n@@ -1,1 +1,1 @@\n-old\n+new\n"
+		if diff, err := os.ReadFile(destFile); err != nil || string(diff) != expected {
+			t.Errorf("Expected diff %q, got %q (%v)", expected, diff, err)
+		}
+	})
+}
+
+func TestFileProcessorDeltaLarge(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "large.txt")
+
+	// Files with no lines in common are the worst case for the diff
+	var existing, content strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&existing, "old %d\n", i)
+		fmt.Fprintf(&content, "new %d\n", i)
+	}
+	if err := os.WriteFile(testFile, []byte(existing.String()), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	newProc := func(maxLines int) *fileProcessor {
+		proc, err := newFileProcessorFromConfig(`{
+			"operation": "delta",
+			"path": "` + testFile + `",
+			"max_diff_lines": ` + strconv.Itoa(maxLines) + `
+		}`)
+		if err != nil {
+			t.Fatal("Failed to create processor:", err)
+		}
+		return proc
+	}
+
+	result, err := newProc(3000).Process(context.Background(), service.NewMessage([]byte(content.String())))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+	if added, _ := result[0].MetaGetMut("file_delta_lines_added"); added != int64(3000) {
+		t.Errorf("Expected 3000 lines added, got %v", added)
+	}
+	if removed, _ := result[0].MetaGetMut("file_delta_lines_removed"); removed != int64(3000) {
+		t.Errorf("Expected 3000 lines removed, got %v", removed)
+	}
+
+	diff, _ := result[0].AsBytes()
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatal("Failed to parse diff:", err)
+	}
+	patched, _, _, err := applyUnifiedDiff([]byte(existing.String()), hunks, false)
+	if err != nil {
+		t.Fatal("Failed to apply diff:", err)
+	}
+	if string(patched) != content.String() {
+		t.Error("Expected the diff to transform the file into the message content")
+	}
+
+	if _, err := newProc(2999).Process(context.Background(), service.NewMessage([]byte(content.String()))); err == nil || !strings.Contains(err.Error(), "max_diff_lines") {
+		t.Errorf("Expected inputs exceeding max_diff_lines to be refused, got: %v", err)
+	}
+}
+
+// slowFile counts the reads made of a file and delays each of them in order
+// to simulate high latency storage.
+type slowFile struct {
+	fs.File
+	latency time.Duration
+	reads   *int
+}
+
+func (f *slowFile) Read(p []byte) (int, error) {
+	*f.reads++
+	time.Sleep(f.latency)
+	return f.File.Read(p)
+}
+
+func newSlowFileProcessor(tb testing.TB, path, bufferSize string, latency time.Duration, reads *int) *fileProcessor {
+	tb.Helper()
+
+	conf := `{
+		"operation": "read",
+		"path": "` + path + `",
+		"scanner": { "lines": {} }`
+	if bufferSize != "" {
+		conf += `,
+		"read_buffer_size": "` + bufferSize + `"`
+	}
+	conf += `
+	}`
+
+	proc, err := newFileProcessorFromConfigWithFS(conf, &fileProcessorTestFS{
+		FS: ifs.OS(),
+		open: func(name string) (fs.File, error) {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			return &slowFile{File: f, latency: latency, reads: reads}, nil
+		},
+	})
+	if err != nil {
+		tb.Fatal("Failed to create processor:", err)
+	}
+	return proc
+}
+
+func writeLinesFile(tb testing.TB, lines int) string {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "lines.txt")
+	var buf bytes.Buffer
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&buf, "record number %d of the file\n", i)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		tb.Fatal("Failed to create test file:", err)
+	}
+	return path
+}
+
+func TestFileProcessorReadBufferSize(t *testing.T) {
+	path := writeLinesFile(t, 10000)
+
+	readRecords := func(bufferSize string) int {
+		t.Helper()
+
+		var reads int
+		proc := newSlowFileProcessor(t, path, bufferSize, 0, &reads)
+		result, err := proc.Process(context.Background(), service.NewMessage(nil))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		if len(result) != 10000 {
+			t.Fatalf("Expected 10000 records, got %d", len(result))
+		}
+		return reads
+	}
+
+	unbuffered, buffered := readRecords(""), readRecords("1MiB")
+	if buffered >= unbuffered {
+		t.Errorf("Expected fewer reads with a read buffer, got %d buffered and %d unbuffered", buffered, unbuffered)
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "read",
+		"path": "` + path + `",
+		"read_buffer_size": "0B"
+	}`); err == nil {
+		t.Error("Expected a read_buffer_size of zero to be rejected")
+	}
+}
+
+func BenchmarkFileProcessorReadBufferSize(b *testing.B) {
+	path := writeLinesFile(b, 10000)
+
+	for _, bufferSize := range []string{"", "64KiB", "1MiB"} {
+		name := bufferSize
+		if name == "" {
+			name = "unbuffered"
+		}
+		b.Run(name, func(b *testing.B) {
+			var reads int
+			proc := newSlowFileProcessor(b, path, bufferSize, 100*time.Microsecond, &reads)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := proc.Process(context.Background(), service.NewMessage(nil)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+		})
+	}
+}
+
+func TestFileProcessorStatPermissionMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "perms.txt")
+	if err := os.WriteFile(testFile, []byte("perms"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "stat",
+		"path": "` + testFile + `"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	tests := []struct {
+		mode          fs.FileMode
+		octal         string
+		worldWritable bool
+		setuid        bool
+		setgid        bool
+	}{
+		{mode: 0o644, octal: "0644"},
+		{mode: 0o666, octal: "0666", worldWritable: true},
+		{mode: 0o755 | fs.ModeSetuid, octal: "4755", setuid: true},
+		{mode: 0o750 | fs.ModeSetgid, octal: "2750", setgid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.octal, func(t *testing.T) {
+			if err := os.Chmod(testFile, test.mode); err != nil {
+				t.Fatal("Failed to set file mode:", err)
+			}
+
+			result, err := proc.Process(context.Background(), service.NewMessage(nil))
+			if err != nil {
+				t.Fatal("Process failed:", err)
+			}
+
+			expected := map[string]any{
+				"file_mode_octal":     test.octal,
+				"file_world_writable": test.worldWritable,
+				"file_setuid":         test.setuid,
+				"file_setgid":         test.setgid,
+			}
+			for key, value := range expected {
+				if actual, _ := result[0].MetaGetMut(key); actual != value {
+					t.Errorf("Expected %v to be %v, got %v", key, value, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestFileProcessorWriteStageDir(t *testing.T) {
+	tempDir := t.TempDir()
+	stageDir := filepath.Join(tempDir, "stage")
+	testFile := filepath.Join(tempDir, "remote", "out.txt")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + testFile + `",
+		"stage_dir": "` + stageDir + `"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	result, err := proc.Process(context.Background(), service.NewMessage([]byte("staged content")))
+	if err != nil {
+		t.Fatal("Process failed:", err)
+	}
+
+	if content, err := os.ReadFile(testFile); err != nil || string(content) != "staged content" {
+		t.Errorf("Expected 'staged content', got '%s' (%v)", content, err)
+	}
+	for _, key := range []string{"file_write_duration_ms", "file_transfer_duration_ms"} {
+		value, _ := result[0].MetaGetMut(key)
+		if _, ok := value.(int64); !ok {
+			t.Errorf("Expected %v to be set to an integer, got %T", key, value)
+		}
+	}
+
+	entries, err := os.ReadDir(stageDir)
+	if err != nil {
+		t.Fatal("Failed to read stage directory:", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the stage directory to be empty, found %d entries", len(entries))
+	}
+}
+
+func TestFileProcessorACL(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "acl.txt")
+	if err := os.WriteFile(testFile, []byte("acl"), 0o640); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+	if err := os.Chmod(testFile, 0o640); err != nil {
+		t.Fatal("Failed to set file mode:", err)
+	}
+
+	getACL := func(t *testing.T) any {
+		t.Helper()
+		proc, err := newFileProcessorFromConfig(`{"operation": "getfacl", "path": "` + testFile + `"}`)
+		if err != nil {
+			t.Fatal("Failed to create processor:", err)
+		}
+		result, err := proc.Process(context.Background(), service.NewMessage(nil))
+		if errors.Is(err, errACLUnsupported) {
+			t.Skip("ACLs are not supported:", err)
+		}
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		acl, _ := result[0].MetaGetMut("file_acl")
+		return acl
+	}
+
+	minimal := []any{
+		map[string]any{"tag": "user", "perms": "rw-"},
+		map[string]any{"tag": "group", "perms": "r--"},
+		map[string]any{"tag": "other", "perms": "---"},
+	}
+	if acl := getACL(t); !reflect.DeepEqual(acl, minimal) {
+		t.Errorf("Expected ACL %v, got %v", minimal, acl)
+	}
+
+	proc, err := newFileProcessorFromConfig(`{"operation": "setfacl", "path": "` + testFile + `"}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	extended := []any{
+		map[string]any{"tag": "user", "perms": "rw-"},
+		map[string]any{"tag": "user", "id": int64(4242), "perms": "r--"},
+		map[string]any{"tag": "group", "perms": "r--"},
+		map[string]any{"tag": "mask", "perms": "r--"},
+		map[string]any{"tag": "other", "perms": "---"},
+	}
+	msg := service.NewMessage(nil)
+	msg.SetStructured(extended)
+	if _, err := proc.Process(context.Background(), msg); err != nil {
+		if errors.Is(err, errACLUnsupported) {
+			t.Skip("ACLs are not supported:", err)
+		}
+		t.Fatal("Process failed:", err)
+	}
+	if acl := getACL(t); !reflect.DeepEqual(acl, extended) {
+		t.Errorf("Expected ACL %v, got %v", extended, acl)
+	}
+
+	// Errors are reported in metadata when fail_on_error is disabled
+	proc, err = newFileProcessorFromConfig(`{
+		"operation": "getfacl",
+		"path": "` + filepath.Join(tempDir, "missing.txt") + `",
+		"fail_on_error": false
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	result, err := proc.Process(context.Background(), service.NewMessage(nil))
+	if err != nil {
+		t.Fatal("Expected the error to be reported in metadata, got:", err)
+	}
+	if aclErr, _ := result[0].MetaGet("file_acl_error"); aclErr == "" {
+		t.Error("Expected file_acl_error to be set")
+	}
+}
+
+func TestPOSIXACLEncoding(t *testing.T) {
+	entries := []any{
+		map[string]any{"tag": "other", "perms": "r--"},
+		map[string]any{"tag": "group", "id": float64(100), "perms": "rwx"},
+		map[string]any{"tag": "user", "perms": "rw-"},
+		map[string]any{"tag": "mask", "perms": "rwx"},
+		map[string]any{"tag": "group", "perms": "r-x"},
+	}
+	data, err := encodePOSIXACL(entries)
+	if err != nil {
+		t.Fatal("Failed to encode ACL:", err)
+	}
+
+	decoded, err := decodePOSIXACL(data)
+	if err != nil {
+		t.Fatal("Failed to decode ACL:", err)
+	}
+	expected := []any{
+		map[string]any{"tag": "user", "perms": "rw-"},
+		map[string]any{"tag": "group", "perms": "r-x"},
+		map[string]any{"tag": "group", "id": int64(100), "perms": "rwx"},
+		map[string]any{"tag": "mask", "perms": "rwx"},
+		map[string]any{"tag": "other", "perms": "r--"},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %v, got %v", expected, decoded)
+	}
+
+	if _, err := encodePOSIXACL([]any{map[string]any{"tag": "owner", "perms": "rw-"}}); err == nil {
+		t.Error("Expected an unknown tag to be rejected")
+	}
+	if _, err := encodePOSIXACL([]any{map[string]any{"tag": "user", "perms": "rwz"}}); err == nil {
+		t.Error("Expected invalid permissions to be rejected")
+	}
+}
+
+func TestFileProcessorStaleTempFileSweepStageDir(t *testing.T) {
+	tempDir := t.TempDir()
+	stageDir := filepath.Join(tempDir, "stage")
+	if err := os.Mkdir(stageDir, 0o755); err != nil {
+		t.Fatal("Failed to create stage directory:", err)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	files := map[string]bool{
+		"a.txt.tmp_1234_0123456789abcdef": false, // stale, removed
+		"b.json.tmp_0123456789abcdef":     false, // stale for another name, removed
+		"c.txt.tmp_notours":               true,  // different pattern, kept
+		"notes.txt":                       true,  // not a temporary file, kept
+	}
+	for name := range files {
+		path := filepath.Join(stageDir, name)
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal("Failed to set file times:", err)
+		}
+	}
+
+	if _, err := newFileProcessorFromConfig(`{
+		"operation": "write",
+		"path": "` + filepath.Join(tempDir, "${! @name }") + `",
+		"stage_dir": "` + stageDir + `",
+		"stale_temp_file_age": "1h"
+	}`); err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(stageDir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("Expected '%s' kept=%v, but exists=%v", name, kept, exists)
+		}
+	}
+}
+
+// syncRecordingFile records the paths of the files that are synced.
+type syncRecordingFile struct {
+	*os.File
+	record func(name string)
+}
+
+func (f *syncRecordingFile) Sync() error {
+	f.record(f.Name())
+	return f.File.Sync()
+}
+
+func newSyncRecordingFileProcessor(tb testing.TB, conf string) (proc *fileProcessor, synced func() (files, dirs int)) {
+	tb.Helper()
+
+	var mut sync.Mutex
+	var files, dirs int
+	proc, err := newFileProcessorFromConfigWithFS(conf, &fileProcessorTestFS{
+		FS: ifs.OS(),
+		openFile: func(name string, flag int, perm fs.FileMode) (fs.File, error) {
+			f, err := os.OpenFile(name, flag, perm)
+			if err != nil {
+				return nil, err
+			}
+			return &syncRecordingFile{File: f, record: func(name string) {
+				mut.Lock()
+				defer mut.Unlock()
+				if info, err := os.Stat(name); err == nil && info.IsDir() {
+					dirs++
+				} else {
+					files++
+				}
+			}}, nil
+		},
+	})
+	if err != nil {
+		tb.Fatal("Failed to create processor:", err)
+	}
+	tb.Cleanup(func() { _ = proc.Close(context.Background()) })
+
+	return proc, func() (int, int) {
+		mut.Lock()
+		defer mut.Unlock()
+		return files, dirs
+	}
+}
+
+func TestFileProcessorWriteSyncPolicy(t *testing.T) {
+	const writes = 10
+
+	tests := []struct {
+		policy        string
+		expectedFiles int
+		maxDirs       int
+	}{
+		{policy: "none"},
+		{policy: "each", expectedFiles: writes, maxDirs: writes},
+		{policy: "interval", expectedFiles: writes, maxDirs: writes / 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			tempDir := t.TempDir()
+			proc, synced := newSyncRecordingFileProcessor(t, `{
+				"operation": "write",
+				"path": "`+filepath.Join(tempDir, "${! @id }.txt")+`",
+				"sync_policy": "`+test.policy+`",
+				"sync_interval": "200ms"
+			}`)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, writes)
+			for i := 0; i < writes; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					msg := service.NewMessage([]byte("durable"))
+					msg.MetaSetMut("id", strconv.Itoa(i))
+					_, err := proc.Process(context.Background(), msg)
+					errs <- err
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal("Process failed:", err)
+				}
+			}
+
+			files, dirs := synced()
+			if files != test.expectedFiles {
+				t.Errorf("Expected %d files to be synced, got %d", test.expectedFiles, files)
+			}
+			if runtime.GOOS != "windows" && test.expectedFiles > 0 && dirs == 0 {
+				t.Error("Expected the directory to be synced")
+			}
+			if dirs > test.maxDirs {
+				t.Errorf("Expected at most %d directory syncs, got %d", test.maxDirs, dirs)
+			}
+		})
+	}
+}
+
+func BenchmarkFileProcessorWriteSyncPolicy(b *testing.B) {
+	for _, policy := range []string{"none", "each", "interval"} {
+		b.Run(policy, func(b *testing.B) {
+			tempDir := b.TempDir()
+			proc, err := newFileProcessorFromConfig(`{
+				"operation": "write",
+				"path": "` + filepath.Join(tempDir, "${! @id }.txt") + `",
+				"sync_policy": "` + policy + `",
+				"sync_interval": "10ms"
+			}`)
+			if err != nil {
+				b.Fatal("Failed to create processor:", err)
+			}
+			defer proc.Close(context.Background())
+
+			var id atomic.Int64
+			content := bytes.Repeat([]byte("x"), 4096)
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					msg := service.NewMessage(content)
+					msg.MetaSetMut("id", strconv.FormatInt(id.Add(1)%1024, 10))
+					if _, err := proc.Process(context.Background(), msg); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestFileProcessorPublishVersioned(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires elevated privileges on Windows")
+	}
+
+	tempDir := t.TempDir()
+	linkPath := filepath.Join(tempDir, "data")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "publish_versioned",
+		"path": "` + linkPath + `",
+		"keep_versions": 2
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	var versionPaths []string
+	for i, expectedPruned := range []int64{0, 0, 1} {
+		content := fmt.Sprintf("version %d", i)
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte(content)))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		versionPath, _ := result[0].MetaGet("file_version_path")
+		versionPaths = append(versionPaths, versionPath)
+		if pruned, _ := result[0].MetaGetMut("file_versions_pruned"); pruned != expectedPruned {
+			t.Errorf("Publish %d: expected %d versions pruned, got %v", i, expectedPruned, pruned)
+		}
+
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			t.Fatal("Failed to read link:", err)
+		}
+		if target != filepath.Base(versionPath) {
+			t.Errorf("Expected link to point to '%s', got '%s'", filepath.Base(versionPath), target)
+		}
+		if data, err := os.ReadFile(linkPath); err != nil || string(data) != content {
+			t.Errorf("Expected '%s' through the link, got '%s' (%v)", content, data, err)
+		}
+	}
+
+	if _, err := os.Stat(versionPaths[0]); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the oldest version to be pruned, got: %v", err)
+	}
+	for _, versionPath := range versionPaths[1:] {
+		if _, err := os.Stat(versionPath); err != nil {
+			t.Errorf("Expected version '%s' to be kept: %v", versionPath, err)
+		}
+	}
+
+	regularFile := filepath.Join(tempDir, "regular")
+	if err := os.WriteFile(regularFile, []byte("not a link"), 0o644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+	proc, err = newFileProcessorFromConfig(`{
+		"operation": "publish_versioned",
+		"path": "` + regularFile + `"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := proc.Process(context.Background(), service.NewMessage([]byte("new"))); err == nil {
+		t.Error("Expected publishing over a regular file to fail")
+	}
+}
+
+func TestFileProcessorPublishVersionedResourceFS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires elevated privileges on Windows")
+	}
+
+	root := t.TempDir()
+	virtualFS := &rootedTestFS{root: root}
+
+	proc, err := newFileProcessorFromConfigWithFS(`{
+		"operation": "publish_versioned",
+		"path": "/pub/data",
+		"keep_versions": 1
+	}`, virtualFS)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	var versionPath string
+	for i, expectedPruned := range []int64{0, 1} {
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte(fmt.Sprintf("version %d", i))))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		versionPath, _ = result[0].MetaGet("file_version_path")
+		if pruned, _ := result[0].MetaGetMut("file_versions_pruned"); pruned != expectedPruned {
+			t.Errorf("Publish %d: expected %d versions pruned, got %v", i, expectedPruned, pruned)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "pub"))
+	if err != nil {
+		t.Fatal("Failed to read publish directory:", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected the link and latest version within the virtual filesystem, got %d entries", len(entries))
+	}
+	content, err := os.ReadFile(filepath.Join(root, "pub", "data"))
+	if err != nil {
+		t.Fatal("Failed to read through the published link:", err)
+	}
+	if string(content) != "version 1" {
+		t.Errorf("Expected the link to point to 'version 1', got '%s'", content)
+	}
+	if target, _ := os.Readlink(filepath.Join(root, "pub", "data")); target != filepath.Base(versionPath) {
+		t.Errorf("Expected the link target '%s', got '%s'", filepath.Base(versionPath), target)
+	}
+
+	// Filesystems that cannot create symbolic links are rejected
+	unsupported, err := newFileProcessorFromConfigWithFS(`{
+		"operation": "publish_versioned",
+		"path": "`+filepath.Join(root, "other")+`"
+	}`, &fileProcessorTestFS{FS: ifs.OS()})
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+	if _, err := unsupported.Process(context.Background(), service.NewMessage([]byte("content"))); err == nil || !strings.Contains(err.Error(), "supports symbolic links") {
+		t.Errorf("Expected an unsupported filesystem to be rejected, got: %v", err)
+	}
+	if versions, _ := filepath.Glob(filepath.Join(root, "other*")); len(versions) > 0 {
+		t.Errorf("Expected nothing to be written to an unsupported filesystem, got %v", versions)
+	}
+}
+
+func TestFileProcessorAppend(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "logs", "app.log")
+
+	proc, err := newFileProcessorFromConfig(`{
+		"operation": "append",
+		"path": "` + testFile + `"
+	}`)
+	if err != nil {
+		t.Fatal("Failed to create processor:", err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		result, err := proc.Process(context.Background(), service.NewMessage([]byte(line)))
+		if err != nil {
+			t.Fatal("Process failed:", err)
+		}
+		if content, _ := result[0].AsBytes(); string(content) != line {
+			t.Errorf("Expected message content '%s' to be unchanged, got '%s'", line, content)
+		}
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal("Failed to read file:", err)
+	}
+	if string(content) != "first\nsecond\nthird\n" {
+		t.Errorf("Expected appended content, got '%s'", content)
+	}
+
+	tempFiles, _ := filepath.Glob(filepath.Join(tempDir, "logs", "*.tmp_*"))
+	if len(tempFiles) > 0 {
+		t.Errorf("Found leftover temporary files: %v", tempFiles)
+	}
+}
@@ -210,6 +210,26 @@ func (f *FS) MkdirAll(path string, perm fs.FileMode) error {
 	return f.i.MkdirAll(path, perm)
 }
 
+// Rename renames (moves) oldpath to newpath. When the underlying filesystem
+// does not support renames the os package is used instead.
+func (f *FS) Rename(oldpath, newpath string) error {
+	return ifs.Rename(f.i, oldpath, newpath)
+}
+
+// Lstat returns a FileInfo describing the named file without following a final
+// symbolic link. When the underlying filesystem does not support this an error
+// wrapping errors.ErrUnsupported is returned.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	return ifs.Lstat(f.i, name)
+}
+
+// Symlink creates newname as a symbolic link to oldname. When the underlying
+// filesystem does not support this an error wrapping errors.ErrUnsupported is
+// returned.
+func (f *FS) Symlink(oldname, newname string) error {
+	return ifs.Symlink(f.i, oldname, newname)
+}
+
 // FS returns an fs.FS implementation that provides isolation or customised
 // behaviour for components that access the filesystem. For example, this might
 // be used to tally files being accessed by components for observability